@@ -0,0 +1,662 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/store"
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// migrate runs "migrate status|check|up" and returns the exit code
+func migrate(args []string) int {
+	sub := "status"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	rosterDB, err := store.Open(dbDSN())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	switch sub {
+	case "status":
+		status, err := rosterDB.MigrationStatus()
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%04d  %-30s %s\n", s.Version, s.Name, applied)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "check":
+		pending, err := rosterDB.PendingMigrations()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(pending) > 0 {
+			fmt.Printf("%d pending migrations\n", len(pending))
+			return 1
+		}
+		fmt.Println("schema is up to date")
+	case "up":
+		applied, err := rosterDB.Migrate()
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n", sub)
+		return 2
+	}
+	return 0
+}
+
+// diff runs "diff [run-id|runs]" and returns the exit code
+func diff(args []string) int {
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	if len(args) > 0 && args[0] == "runs" {
+		runs, err := rosterDB.SelectRosterRuns()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, r := range runs {
+			fmt.Printf("%5d  %s  %d rows\n", r.ID, r.At.Local().Format("2006-01-02 15:04"), r.Rows)
+		}
+		return 0
+	}
+
+	var d *types.RosterDiff
+	if len(args) > 0 {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "diff: run id must be a number: %v\n", err)
+			return 2
+		}
+		d, err = rosterDB.SelectDiff(id)
+	} else {
+		d, err = rosterDB.LatestDiff()
+	}
+	if err == sql.ErrNoRows {
+		fmt.Fprintln(os.Stderr, "no roster runs recorded")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("%s (%s)\n", d.Summary(), d.Run.At.Local().Format("2006-01-02 15:04"))
+	for _, c := range d.Changes {
+		fmt.Println(c)
+	}
+	return 0
+}
+
+func readRules(name string) (types.Rules, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return types.ReadRules(f)
+}
+
+// currentTerm returns the shortest term in session on day t in the -terms calendar, or ""
+// without one
+func currentTerm(t time.Time) (string, error) {
+	if *terms == "" {
+		return "", nil
+	}
+	f, err := os.Open(*terms)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	tc, err := types.ReadTermCalendar(f)
+	if err != nil {
+		return "", err
+	}
+	if on := tc.TermsOn(t); len(on) > 0 {
+		return on[0].String(), nil
+	}
+	return "", nil
+}
+
+func selectRules() (types.Rules, error) {
+	rosterDB, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer rosterDB.Close()
+	return rosterDB.SelectRules()
+}
+
+// listRules prints the rules from -rules, or the roster_rules table, and returns the exit code
+func listRules() int {
+	var rs types.Rules
+	var err error
+	if *rules != "" {
+		rs, err = readRules(*rules)
+	} else {
+		rs, err = selectRules()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	for _, r := range rs {
+		target := r.Teacher
+		if r.Title != "" {
+			target += " " + strconv.Quote(r.Title)
+		}
+		fmt.Printf("%3d  %-24s %-10s %-30s %+v\n", r.Position, r.Name, r.Action, target, r.Match)
+	}
+	return 0
+}
+
+// override runs "override list|add|remove|swap|delete|prune" and returns the exit code
+func override(args []string) int {
+	sub := "list"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	switch sub {
+	case "list":
+		ovs, err := rosterDB.SelectOverrides()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		now := time.Now()
+		for _, o := range ovs {
+			status := "active"
+			switch {
+			case !o.Active(now):
+				status = "expired"
+			case !o.Redundant.IsZero():
+				status = "redundant since " + o.Redundant.Local().Format("2006-01-02")
+			}
+			fmt.Printf("%s [%s]\n", o, status)
+		}
+	case types.OverrideAdd, types.OverrideRemove, types.OverrideSwap:
+		o, code := parseOverride(sub, args)
+		if o == nil {
+			return code
+		}
+		if err := rosterDB.InsertOverride(o); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("added", o)
+	case "delete":
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "override delete: missing id")
+			return 2
+		}
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "override delete: id must be a number: %v\n", err)
+			return 2
+		}
+		if err := rosterDB.DeleteOverride(id); err != nil {
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("no override %d", id)
+			}
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "prune":
+		n, err := rosterDB.DeleteExpiredOverrides(time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("deleted %d expired overrides\n", n)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown override command %q\n", sub)
+		return 2
+	}
+	return 0
+}
+
+// parseOverride reads the flags of "override add|remove|swap". A nil override comes with the exit code
+func parseOverride(kind string, args []string) (*types.Override, int) {
+	fs := flag.NewFlagSet("override "+kind, flag.ContinueOnError)
+	o := &types.Override{Kind: kind}
+	fs.StringVar(&o.SyncID, "section", "", "Section sync id")
+	fs.StringVar(&o.School, "school", "", "School of the section; every school with the sync id if empty")
+	fs.StringVar(&o.Year, "year", "", "School year of the section; the school's current year if empty")
+	fs.StringVar(&o.PermID, "perm", "", "Student perm id (add, remove)")
+	fs.StringVar(&o.StudentName, "name", "", "Student name if they are not on the roster yet (add)")
+	fs.StringVar(&o.Teacher, "teacher", "", "New teacher email (swap)")
+	fs.StringVar(&o.Author, "author", os.Getenv("USER"), "Who asked for the change")
+	fs.StringVar(&o.Reason, "reason", "", "Why Synergy is wrong")
+	expires := fs.String("expires", "", "Last day the override applies, YYYY-MM-DD")
+	if err := fs.Parse(args); err != nil {
+		return nil, 2
+	}
+	if *expires != "" {
+		day, err := time.ParseInLocation("2006-01-02", *expires, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "override %s: %v\n", kind, err)
+			return nil, 2
+		}
+		o.Expires = day.AddDate(0, 0, 1)
+	}
+	return o, 0
+}
+
+// sectionStaff runs "staff SYNC-ID|import FILE|delete SYNC-ID EMAIL" and returns the exit code
+func sectionStaff(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "staff: missing section sync id or command")
+		return 2
+	}
+
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	switch args[0] {
+	case "import":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "staff import: missing file")
+			return 2
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		staff, err := types.ReadSectionStaff(f)
+		f.Close()
+		if err == nil {
+			err = rosterDB.ImportSectionStaff(staff)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("imported %d section staff\n", len(staff))
+	case "delete":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "staff delete: want sync id and email")
+			return 2
+		}
+		if err := rosterDB.DeleteSectionStaff(args[1], args[2]); err != nil {
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("%s is not attached to %s", args[2], args[1])
+			}
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	default:
+		staff, err := rosterDB.SelectSectionStaff(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, st := range staff {
+			fmt.Printf("%-30s %-10s %s\n", st.Email, st.Role, st.Source)
+		}
+	}
+	return 0
+}
+
+// partitions lists the schools and years on the roster and returns the exit code
+func partitions() int {
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	ps, err := rosterDB.SelectPartitions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, p := range ps {
+		fmt.Printf("%-10s %s\n", p.Year, p.School)
+	}
+	return 0
+}
+
+// query prints the roster rows matching its flags and returns the exit code
+func query(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	var q types.Query
+	fs.StringVar(&q.School, "school", "", "School (organization name); every school if empty")
+	fs.StringVar(&q.Year, "year", "", "School year, or * for every year (default the current year)")
+	fs.StringVar(&q.Term, "term", "", "Term or term name")
+	fs.StringVar(&q.Per, "per", "", "Period")
+	fs.StringVar(&q.Teacher, "teacher", "", "Teacher email")
+	fs.StringVar(&q.Course, "course", "", "Course code or full course id and title")
+	fs.StringVar(&q.SectionID, "section", "", "Synergy section id")
+	fs.StringVar(&q.Room, "room", "", "Room")
+	fs.StringVar(&q.Grade, "grade", "", "Student grade")
+	fs.StringVar(&q.Gender, "gender", "", "Student gender")
+	fs.StringVar(&q.Student, "student", "", "Student perm id or part of a name")
+	sortBy := fs.String("sort", "", "Comma separated fields to sort by, - first for descending")
+	fs.IntVar(&q.Limit, "limit", 0, "Most rows to print (0 for all)")
+	fs.IntVar(&q.Offset, "offset", 0, "Rows to skip")
+	fields := fs.String("fields", "", "Comma separated fields to print (default all): "+strings.Join(types.Stu415Fields, ","))
+	format := fs.String("format", formatTable, "Output format: table, csv or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	q.Sort = types.ParseFieldList(*sortBy)
+	q.Fields = types.ParseFieldList(*fields)
+	if err := q.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	s415s, err := rosterDB.SelectStu415s(q)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	cols := q.Columns()
+	rows := make([][]string, len(s415s))
+	for i, s := range s415s {
+		rows[i] = make([]string, len(cols))
+		for j, c := range cols {
+			rows[i][j] = *s.Field(c)
+		}
+	}
+	if err := writeOutput(os.Stdout, *format, s415s, cols, rows); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// search prints the students, staff and sections matching its arguments and returns the exit code
+func search(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "search: missing text")
+		return 2
+	}
+
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	res, err := rosterDB.Search(strings.Join(args, " "))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, st := range res.Students {
+		fmt.Printf("student  %-12s %-30s %s %s\n", strings.TrimSuffix(st.PermID, types.PermIDDomain), st.Name, st.Grade, st.School)
+		for _, s := range st.Schedule {
+			fmt.Printf("           %-4s %-30s %-30s %s\n", s.Per, s.CourseIDAndTitle, s.Teacher, s.Room)
+		}
+	}
+	for _, st := range res.Staff {
+		fmt.Printf("staff    %-30s %s\n", st.Email, st.FormattedName)
+	}
+	for _, c := range res.Sections {
+		fmt.Printf("section  %-4s %-30s %-30s %s (%d students)\n", c.Per, c.Title, c.Teacher, c.ID, len(c.Students))
+	}
+	if res.Len() == 0 {
+		fmt.Println("no matches")
+		return 1
+	}
+	return 0
+}
+
+// report prints "report sections|teachers|periods|caps" and returns the exit code
+func report(args []string) int {
+	kind := "sections"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		kind, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("report "+kind, flag.ContinueOnError)
+	var sc types.Scope
+	fs.StringVar(&sc.School, "school", "", "School (organization name); every school if empty")
+	fs.StringVar(&sc.Year, "year", "", "School year, or * for every year (default the current year)")
+	caps := types.DefaultSizeCaps
+	fs.IntVar(&caps.Min, "min", caps.Min, "Flag sections with fewer students (0 disables)")
+	fs.IntVar(&caps.Max, "max", caps.Max, "Flag sections with more students (0 disables)")
+	format := fs.String("format", formatTable, "Output format: table, csv or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	a, err := rosterDB.SelectAnalytics(caps, sc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var v interface{}
+	var header []string
+	var rows [][]string
+	switch kind {
+	case "sections", "caps":
+		sections := a.Sections
+		if kind == "caps" {
+			sections = a.OutOfBounds()
+		}
+		v = sections
+		header = []string{"per", "course", "teacher", "room", "students", "grades", "genders", "status", "sync_id"}
+		for _, s := range sections {
+			rows = append(rows, []string{s.Per, s.Course, s.Teacher, s.Room, strconv.Itoa(s.Students), types.FormatCounts(s.Grades), types.FormatCounts(s.Genders), s.Status, s.SyncID})
+		}
+	case "teachers":
+		v = a.Teachers
+		header = []string{"teacher", "name", "sections", "students"}
+		for _, t := range a.Teachers {
+			rows = append(rows, []string{t.Teacher, t.Name, strconv.Itoa(t.Sections), strconv.Itoa(t.Students)})
+		}
+	case "periods":
+		v = a.Periods
+		header = []string{"per", "sections", "students"}
+		for _, p := range a.Periods {
+			rows = append(rows, []string{p.Per, strconv.Itoa(p.Sections), strconv.Itoa(p.Students)})
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown report %q\n", kind)
+		return 2
+	}
+	if err := writeOutput(os.Stdout, *format, v, header, rows); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// check prints "check [run-id|runs]" data quality findings and returns the exit code
+func check(args []string) int {
+	target := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	kind := fs.String("kind", "", "Only list findings of this kind: "+strings.Join(types.FindingKinds, ", "))
+	format := fs.String("format", formatTable, "Output format: table, csv or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	if target == "runs" {
+		runs, err := rosterDB.SelectCheckRuns()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, r := range runs {
+			fmt.Printf("%5d  %s  %d rows\n", r.ID, r.At.Local().Format("2006-01-02 15:04"), r.Rows)
+		}
+		return 0
+	}
+
+	var r *types.CheckReport
+	if target != "" {
+		id, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "check: run id must be a number: %v\n", err)
+			return 2
+		}
+		r, err = rosterDB.SelectCheckReport(id, *kind)
+	} else {
+		r, err = rosterDB.LatestCheckReport(*kind)
+	}
+	if err == sql.ErrNoRows {
+		fmt.Fprintln(os.Stderr, "no check runs recorded")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *format == formatTable {
+		fmt.Printf("%s (%s)\n", r.Summary(), r.Run.At.Local().Format("2006-01-02 15:04"))
+		for _, f := range r.Findings {
+			fmt.Println(f)
+		}
+		return 0
+	}
+	header := []string{"kind", "school", "year", "sync_id", "course", "per", "term", "teacher", "perm_id", "student_name", "detail"}
+	rows := make([][]string, len(r.Findings))
+	for i, f := range r.Findings {
+		rows[i] = []string{f.Kind, f.School, f.Year, f.SyncID, f.Course, f.Per, f.Term, f.Teacher, f.PermID, f.StudentName, f.Detail}
+	}
+	if err := writeOutput(os.Stdout, *format, r, header, rows); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// rooms prints "rooms [use|double|empty]" and returns the exit code
+func rooms(args []string) int {
+	view := "use"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		view, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("rooms "+view, flag.ContinueOnError)
+	var sc types.Scope
+	fs.StringVar(&sc.School, "school", "", "School (organization name); every school if empty")
+	fs.StringVar(&sc.Year, "year", "", "School year, or * for every year (default the current year)")
+	room := fs.String("room", "", "Only this room")
+	per := fs.String("per", "", "Only this period")
+	term := fs.String("term", "", "Only this term (default the term in session today with -terms, else every term)")
+	format := fs.String("format", formatTable, "Output format: table, csv or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *term == "" {
+		t, err := currentTerm(time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		*term = t
+	}
+
+	rosterDB, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rosterDB.Close()
+
+	r, err := rosterDB.SelectRoomReport(sc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	r = r.Filter(*room, *per, *term)
+
+	var v interface{}
+	var header []string
+	var rows [][]string
+	switch view {
+	case "use", "double":
+		uses := r.Uses
+		if view == "double" {
+			uses = r.DoubleBooked()
+		}
+		v = uses
+		header = []string{"school", "room", "per", "term", "course", "teacher", "students", "double_booked", "sync_id"}
+		for _, u := range uses {
+			double := ""
+			if u.DoubleBooked {
+				double = "yes"
+			}
+			for _, s := range u.Sections {
+				rows = append(rows, []string{u.School, u.Room, u.Per, u.Term, s.Course, s.Teacher, strconv.Itoa(s.Students), double, s.SyncID})
+			}
+		}
+	case "empty":
+		v = r.Empty
+		header = []string{"school", "per", "term", "rooms"}
+		for _, e := range r.Empty {
+			rows = append(rows, []string{e.School, e.Per, e.Term, strings.Join(e.Rooms, " ")})
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown rooms view %q\n", view)
+		return 2
+	}
+	if err := writeOutput(os.Stdout, *format, v, header, rows); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
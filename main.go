@@ -1,40 +1,240 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/matthewkappus/rosterUpdate/src/store"
+	"github.com/matthewkappus/rosterUpdate/src/types"
 )
 
 var (
 	u = flag.String("u", "", "Synergy User Name: Must have admin rights")
 	p = flag.String("p", "", "Synergy Password: Must have admin rights")
+
+	syncSchool = flag.Bool("syncid-school", false, "Include the school in class sync ids")
+	syncYear   = flag.Bool("syncid-year", false, "Include the school year in class sync ids")
+
+	teachers = flag.String("teachers", "", "Optional csv of name,email rows pinning teacher names to emails")
+	rules    = flag.String("rules", "", "Optional JSON file of roster rules to use instead of the roster_rules table")
+	terms    = flag.String("terms", "", "Optional csv of term,start,end rows (dates as YYYY-MM-DD); rooms shows the term in session today")
+
+	force           = flag.Bool("force", false, "Replace the roster even if safety checks fail")
+	maxShrink       = flag.Float64("max-shrink", types.DefaultGuard.MaxShrinkPercent, "Refuse updates whose row count drops more than this percent (0 disables)")
+	maxTeachersLost = flag.Int("max-teachers-lost", types.DefaultGuard.MaxTeachersLost, "Refuse updates where more teachers lose all classes (-1 disables)")
+	schools         = flag.String("schools", "", "Comma separated schools every download must include, or * for every stored school (default only the schools downloaded)")
+	allowSchoolLoss = flag.Bool("allow-school-loss", false, "Accept updates missing a -schools school stored for the year they cover")
+	allowYearChange = flag.Bool("allow-year-change", false, "Accept updates starting a new school year for a stored school")
+	allowOldYear    = flag.Bool("allow-old-year", false, "Accept updates for a school year older than the school's latest stored year")
+	maxBadRows      = flag.Int("max-bad-rows", 0, "Skip up to this many rows missing a perm id, course or sync id instead of failing")
+
+	dataDir = flag.String("data", "data", "Directory for the roster database, created if missing")
+	dsn     = flag.String("db", "", "SQLite path or file: URI, postgres:// URL, or memory (default <data>/rosters.db)")
+	logDir  = flag.String("log", "log", "Directory for rosterUpdate.log, created if missing")
 )
 
+// schoolList splits the comma separated -schools flag, dropping blanks
+func schoolList(list string) []string {
+	var schools []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			schools = append(schools, s)
+		}
+	}
+	return schools
+}
+
+// dbDSN returns -db, or rosters.db in -data
+func dbDSN() string {
+	if *dsn != "" {
+		return *dsn
+	}
+	return filepath.Join(*dataDir, "rosters.db")
+}
+
+// openStore returns the store -db names, migrating a database
+func openStore() (store.RosterStore, error) {
+	return store.NewStore(dbDSN(), store.Options{})
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] [command]
+
+Commands:
+  update                  download rosters from Synergy (default; requires -u and -p)
+  migrate status          list schema migrations and whether they are applied
+  migrate check           exit 1 if migrations are pending
+  migrate up              apply pending migrations
+  partitions              list the schools and school years on the roster
+  query [flags]           list roster rows filtered by -school, -year, -term, -per, -teacher,
+                          -course, -section, -room, -grade, -gender or -student, with -sort,
+                          -limit, -offset, -fields and -format table|csv|json (query -h for details)
+  report [sections|teachers|periods|caps] [-school S] [-year Y] [-min N] [-max N] [-format table|csv|json]
+                          section sizes with grade and gender breakdowns, teacher loads, period
+                          counts, or the sections outside the -min and -max caps
+  rooms [use|double|empty] [-school S] [-year Y] [-room R] [-per P] [-term T] [-format table|csv|json]
+                          the sections, teachers and students in each room by period, double
+                          booked rooms, or the rooms free each period (-term defaults to the
+                          current quarter with -terms)
+  search TEXT             find students, staff and sections by partial name, perm id, email or course
+                          (build with -tags sqlite_fts5 to rank matches with a full-text index)
+  diff [run-id]           list roster changes made by the latest or given update
+  diff runs               list update runs
+  check [run-id] [-kind KIND] [-format table|csv|json]
+                          list the data quality findings of the latest or given download
+  check runs              list data quality runs
+  rules                   list the roster rules applied to updates
+  override list           list manual overrides and whether a refresh made them redundant
+  override add|remove|swap -section ID [-school NAME] [-year YEAR] [-perm ID] [-teacher EMAIL] -reason TEXT [-expires YYYY-MM-DD]
+                          keep a local roster change across refreshes
+  override delete ID      delete an override
+  override prune          delete expired overrides
+  staff SYNC-ID           list the staff attached to a section and their roles
+  staff import FILE       attach staff from "sync_id,email,role" csv rows (roles: primary, co-teacher, aide, observer)
+  staff delete SYNC-ID EMAIL
+                          detach an imported staff member from a section
+
+Update exits 3 if safety checks refuse the new roster and 4 if it has bad rows.
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
 func main() {
+	flag.Usage = usage
 	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "", "update":
+		update()
+	case "migrate":
+		os.Exit(migrate(flag.Args()[1:]))
+	case "diff":
+		os.Exit(diff(flag.Args()[1:]))
+	case "check":
+		os.Exit(check(flag.Args()[1:]))
+	case "partitions":
+		os.Exit(partitions())
+	case "query":
+		os.Exit(query(flag.Args()[1:]))
+	case "report":
+		os.Exit(report(flag.Args()[1:]))
+	case "rooms":
+		os.Exit(rooms(flag.Args()[1:]))
+	case "search":
+		os.Exit(search(flag.Args()[1:]))
+	case "rules":
+		os.Exit(listRules())
+	case "override":
+		os.Exit(override(flag.Args()[1:]))
+	case "staff":
+		os.Exit(sectionStaff(flag.Args()[1:]))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func update() {
 	if *u == "" || *p == "" {
 		log.Fatal("Must provide -u and -p flags")
 	}
 
-	f, err := os.OpenFile("log/rosterUpdate.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err := os.MkdirAll(*logDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	logName := filepath.Join(*logDir, "rosterUpdate.log")
+	f, err := os.OpenFile(logName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer f.Close()
 
-	// TODO: create log/ data/ files if not exist
 	logger := log.New(f, "UpdateLog: ", log.Ldate|log.Lshortfile)
-	rosterDB, err := store.New("data/rosters.db")
+	opts := store.Options{
+		Log:          logger,
+		SyncIDScheme: types.SyncIDScheme{School: *syncSchool, Year: *syncYear},
+		Force:        *force,
+		MaxBadRows:   *maxBadRows,
+		Guard: &types.Guard{
+			Schools:          schoolList(*schools),
+			MaxShrinkPercent: *maxShrink,
+			MaxTeachersLost:  *maxTeachersLost,
+			AllowSchoolLoss:  *allowSchoolLoss,
+			AllowYearChange:  *allowYearChange,
+			AllowOldYear:     *allowOldYear,
+		},
+	}
+
+	if *teachers != "" {
+		tf, err := os.Open(*teachers)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		opts.TeacherOverrides, err = types.ReadTeacherOverrides(tf)
+		tf.Close()
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if *rules != "" {
+		if opts.Rules, err = readRules(*rules); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	rosterDB, err := store.NewStore(dbDSN(), opts)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer rosterDB.Close()
+	logger.Printf("database %s, log %s", displayPath(dbDSN()), displayPath(logName))
 
 	logger.Printf("Getting roster for %s", *u)
 	if err = rosterDB.DownloadRosters(time.Minute*2, *u, *p); err != nil {
-		logger.Fatal(err)
-	} else {
-		logger.Print("Updated rosters")
+		logger.Print(err)
+		fmt.Fprintln(os.Stderr, err)
+		rosterDB.Close()
+		f.Close()
+		os.Exit(updateExitCode(err))
 	}
+	logger.Print("Updated rosters")
+}
 
+// updateExitCode is 3 for updates refused by the guard, 4 for too many bad rows and 1 otherwise
+func updateExitCode(err error) int {
+	var guardErr *types.GuardError
+	var rowErrs types.RowErrors
+	var rowErr *types.RowError
+	switch {
+	case errors.As(err, &guardErr):
+		return 3
+	case errors.As(err, &rowErrs), errors.As(err, &rowErr):
+		return 4
+	}
+	return 1
+}
+
+// displayPath returns a database or log path for logging: files are made absolute and
+// URL passwords are hidden
+func displayPath(path string) string {
+	if strings.Contains(path, "://") {
+		if u, err := url.Parse(path); err == nil {
+			return u.Redacted()
+		}
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil && !strings.HasPrefix(path, "file:") && path != "memory" {
+		return abs
+	}
+	return path
 }
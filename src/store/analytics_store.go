@@ -0,0 +1,31 @@
+package store
+
+import "github.com/matthewkappus/rosterUpdate/src/types"
+
+// SelectAnalytics returns section sizes, teacher loads and period counts for the roster,
+// checking section sizes against caps
+func (rs *Roster) SelectAnalytics(caps types.SizeCaps, scope ...types.Scope) (*types.Analytics, error) {
+	args, err := rs.scopeArgs(scope)
+	if err != nil {
+		return nil, err
+	}
+	s415s, err := rs.selectStu415s(selectAllStu415s, args...)
+	if err != nil {
+		return nil, err
+	}
+	return types.Analyze(s415s, caps), nil
+}
+
+// SelectRoomReport returns what meets in each room every period, double booked rooms and
+// the rooms free each period
+func (rs *Roster) SelectRoomReport(scope ...types.Scope) (*types.RoomReport, error) {
+	args, err := rs.scopeArgs(scope)
+	if err != nil {
+		return nil, err
+	}
+	s415s, err := rs.selectStu415s(selectAllStu415s, args...)
+	if err != nil {
+		return nil, err
+	}
+	return types.RoomUtilization(s415s), nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+	// sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// dialect holds what differs between backends. Queries are written once with ? placeholders
+// and SQL that both SQLite and PostgreSQL accept; drivers that number their placeholders
+// are wrapped to rebind them
+type dialect struct {
+	name string
+	// driver is the database/sql driver name
+	driver string
+	// migrations is the embedded directory of the backend's schema migrations
+	migrations string
+}
+
+var (
+	sqliteDialect   = &dialect{name: "sqlite", driver: "sqlite3", migrations: "migrations"}
+	postgresDialect = &dialect{name: "postgres", driver: "roster-postgres", migrations: "migrations/postgres"}
+)
+
+var registerPostgres sync.Once
+
+// backend returns the roster's dialect, SQLite if it was not opened with Open
+func (rs *Roster) backend() *dialect {
+	if rs.dialect == nil {
+		return sqliteDialect
+	}
+	return rs.dialect
+}
+
+// parseDSN picks the backend for dsn and returns the name its driver expects.
+// postgres:// and postgresql:// URLs use PostgreSQL, and anything else a SQLite path or
+// file: URI
+func parseDSN(dsn string) (*dialect, string) {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		registerPostgres.Do(func() { sql.Register(postgresDialect.driver, rebindDriver{&pq.Driver{}}) })
+		return postgresDialect, dsn
+	}
+	return sqliteDialect, dsn
+}
+
+// rebind replaces ? placeholders outside quoted strings with $1, $2...
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n, quoted := 0, false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// rebindDriver wraps a driver that numbers its placeholders
+type rebindDriver struct {
+	driver.Driver
+}
+
+func (d rebindDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return rebindConn{c}, nil
+}
+
+type rebindConn struct {
+	driver.Conn
+}
+
+func (c rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebind(query))
+}
+
+func (c rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, rebind(query))
+	}
+	return c.Prepare(query)
+}
+
+// ExecContext lets multi-statement migrations run without being prepared
+func (c rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c rebindConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
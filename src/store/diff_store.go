@@ -0,0 +1,108 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+const (
+	selectStageStu415s = `SELECT organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, teacher_name, room, prescheduled, sync_id FROM stage_stu415`
+
+	insertRosterRun       = `INSERT INTO roster_runs(at, rows) VALUES(?,?) RETURNING id`
+	insertRosterChange    = `INSERT INTO roster_changes(run_id, kind, organization_name, school_year, sync_id, course_id_and_title, per, teacher, perm_id, student_name, old_value, new_value) VALUES(?,?,?,?,?,?,?,?,?,?,?,?)`
+	selectRosterRuns      = `SELECT id, at, rows FROM roster_runs ORDER BY id DESC`
+	selectRosterRun       = `SELECT id, at, rows FROM roster_runs WHERE id=?`
+	selectLatestRosterRun = `SELECT id, at, rows FROM roster_runs ORDER BY id DESC LIMIT 1`
+	selectRosterChanges   = `SELECT kind, organization_name, school_year, sync_id, course_id_and_title, per, teacher, perm_id, student_name, old_value, new_value FROM roster_changes WHERE run_id=?`
+)
+
+// recordDiff compares the staged roster to the live one and stores the changes as a new run stamped at.
+// The first load into an empty database records the run without changes
+func recordDiff(tx *sql.Tx, live, staged types.Stu415s, at time.Time) (*types.RosterDiff, error) {
+	diff := &types.RosterDiff{Run: types.RosterRun{At: at, Rows: len(staged)}}
+	if err := tx.QueryRow(insertRosterRun, diff.Run.At, diff.Run.Rows).Scan(&diff.Run.ID); err != nil {
+		return nil, err
+	}
+	if len(live) == 0 {
+		return diff, nil
+	}
+
+	diff.Changes = types.DiffStu415s(live, staged)
+	stmt, err := tx.Prepare(insertRosterChange)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	for _, c := range diff.Changes {
+		if _, err := stmt.Exec(diff.Run.ID, c.Kind, c.School, c.Year, c.SyncID, c.Course, c.Per, c.Teacher, c.PermID, c.StudentName, c.Old, c.New); err != nil {
+			return nil, err
+		}
+	}
+	return diff, nil
+}
+
+func scanRosterRun(row interface{ Scan(...interface{}) error }) (*types.RosterRun, error) {
+	run := new(types.RosterRun)
+	if err := row.Scan(&run.ID, &run.At, &run.Rows); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// SelectRosterRuns returns every update run, newest first
+func (rs *Roster) SelectRosterRuns() ([]*types.RosterRun, error) {
+	rows, err := rs.query(selectRosterRuns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]*types.RosterRun, 0)
+	for rows.Next() {
+		run, err := scanRosterRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// SelectDiff returns the changes made by the run with id, or sql.ErrNoRows
+func (rs *Roster) SelectDiff(runID int64) (*types.RosterDiff, error) {
+	run, err := scanRosterRun(rs.QueryRow(selectRosterRun, runID))
+	if err != nil {
+		return nil, err
+	}
+	return rs.selectChanges(run)
+}
+
+// LatestDiff returns the changes made by the most recent update, or sql.ErrNoRows
+func (rs *Roster) LatestDiff() (*types.RosterDiff, error) {
+	run, err := scanRosterRun(rs.QueryRow(selectLatestRosterRun))
+	if err != nil {
+		return nil, err
+	}
+	return rs.selectChanges(run)
+}
+
+func (rs *Roster) selectChanges(run *types.RosterRun) (*types.RosterDiff, error) {
+	rows, err := rs.query(selectRosterChanges, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	diff := &types.RosterDiff{Run: *run}
+	for rows.Next() {
+		c := new(types.RosterChange)
+		if err := rows.Scan(&c.Kind, &c.School, &c.Year, &c.SyncID, &c.Course, &c.Per, &c.Teacher, &c.PermID, &c.StudentName, &c.Old, &c.New); err != nil {
+			return nil, err
+		}
+		diff.Changes = append(diff.Changes, c)
+	}
+	types.SortChanges(diff.Changes)
+	return diff, rows.Err()
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+func TestLatestDiff(t *testing.T) {
+	rs := newTestRoster(t)
+	first := time.Date(2021, 9, 1, 6, 0, 0, 0, time.UTC)
+	rs.Now = func() time.Time { return first }
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+	// Smith's Algebra moves to room 103 and Park joins it
+	second := first.AddDate(0, 0, 1)
+	rs.Now = func() time.Time { return second }
+	if err := rs.UpdateRosters(readRoster(t, `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,"Garcia, Ana",101,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",103,N
+HS,2021,"Kim, Al",2,M,09,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",103,N
+HS,2021,"Park, Jo",3,F,10,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",103,N
+`), testEmails); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := rs.LatestDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Run.At.Equal(second) {
+		t.Errorf("latest run at %s, want %s", d.Run.At, second)
+	}
+	var changes []string
+	for _, c := range d.Changes {
+		changes = append(changes, c.Kind+" "+c.Course+" "+c.StudentName+" "+c.Old+">"+c.New)
+	}
+	want := []string{
+		types.RoomChanged + " 110000 Algebra  102>103",
+		types.EnrollmentAdded + " 110000 Algebra Park, Jo >",
+	}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("changes %q, want %q", changes, want)
+	}
+
+	runs, err := rs.SelectRosterRuns()
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("runs %v, %v", runs, err)
+	}
+	if d, err := rs.SelectDiff(runs[1].ID); err != nil || !d.Run.At.Equal(first) || len(d.Changes) != 0 {
+		t.Errorf("first load diff %+v, %v; want no changes at %s", d, err, first)
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// historyColumns are the flat stu415 columns of enrollment_history
+const historyColumns = `organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, teacher_name, room, prescheduled, sync_id`
+
+// sameRow matches an enrollment_history row h to a stage_stu415 row g on every column
+const sameRow = `g.organization_name=h.organization_name AND g.school_year=h.school_year AND g.student_name=h.student_name AND g.perm_id=h.perm_id AND g.gender=h.gender AND g.grade=h.grade AND g.term_name=h.term_name AND g.per=h.per AND g.term=h.term AND g.section_id=h.section_id AND g.course_id_and_title=h.course_id_and_title AND g.meet_days=h.meet_days AND g.teacher=h.teacher AND g.teacher_name=h.teacher_name AND g.room=h.room AND g.prescheduled=h.prescheduled AND g.sync_id=h.sync_id`
+
+// closeHistory ends rows of the staged partitions that are missing from the staged roster
+var closeHistory = `UPDATE enrollment_history SET valid_to=? WHERE valid_to IS NULL AND (organization_name, school_year) IN (SELECT organization_name, school_year FROM stage_partitions) AND NOT EXISTS (SELECT 1 FROM stage_stu415 g WHERE ` + strings.ReplaceAll(sameRow, "h.", "enrollment_history.") + `)`
+
+// closeStaffHistory ends staff attachments missing from section_staff
+var closeStaffHistory = `UPDATE section_staff_history SET valid_to=? WHERE valid_to IS NULL AND NOT EXISTS (SELECT 1 FROM section_staff ss WHERE ` + strings.ReplaceAll(sameStaff, "h.", "section_staff_history.") + `)`
+
+const (
+	// openHistory starts rows new to the staged roster at the time of roster run r
+	openHistory = `INSERT INTO enrollment_history(` + historyColumns + `, valid_from) SELECT DISTINCT ` + historyColumns + `, r.at FROM stage_stu415 g JOIN roster_runs r ON r.id=? WHERE NOT EXISTS (SELECT 1 FROM enrollment_history h WHERE h.valid_to IS NULL AND ` + sameRow + `)`
+
+	// openStaffHistory starts staff attachments new to section_staff at the time given
+	openStaffHistory = `INSERT INTO section_staff_history(organization_name, school_year, sync_id, email, role, valid_from) SELECT organization_name, school_year, sync_id, email, role, ? FROM section_staff ss WHERE NOT EXISTS (SELECT 1 FROM section_staff_history h WHERE h.valid_to IS NULL AND ` + sameStaff + `)`
+	sameStaff        = `ss.organization_name=h.organization_name AND ss.school_year=h.school_year AND ss.sync_id=h.sync_id AND ss.email=h.email AND ss.role=h.role`
+
+	selectHistoryAsOf = `SELECT ` + historyColumns + ` FROM enrollment_history h WHERE valid_from<=? AND (valid_to IS NULL OR valid_to>?)`
+	orderHistory      = ` ORDER BY per, course_id_and_title, student_name`
+
+	// A primary teacher sees the sections listing them; other roles see every section with the
+	// sync id. Both as attached at the time asked for
+	selectHistoryByTeacherAsOf = selectHistoryAsOf + ` AND (teacher=? OR EXISTS (SELECT 1 FROM section_staff_history ss WHERE ss.organization_name=h.organization_name AND ss.school_year=h.school_year AND ss.sync_id=h.sync_id
+		AND ss.email=? AND ss.role<>'` + types.RolePrimary + `' AND ss.valid_from<=? AND (ss.valid_to IS NULL OR ss.valid_to>?))) AND ` + historyScopeClause + orderHistory
+	selectHistoryBySIDAsOf = selectHistoryAsOf + ` AND sync_id=? AND ` + historyScopeClause + orderHistory
+	selectStaffRolesAsOf   = `SELECT organization_name, school_year, sync_id, role FROM section_staff_history WHERE email=? AND valid_from<=? AND (valid_to IS NULL OR valid_to>?)`
+)
+
+// recordHistory closes history rows that left the roster and opens rows that joined it during run
+func recordHistory(tx *sql.Tx, run types.RosterRun) error {
+	if _, err := tx.Exec(closeHistory, run.At); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(openHistory, run.ID); err != nil {
+		return err
+	}
+	return recordStaffHistory(tx, run.At)
+}
+
+// recordStaffHistory closes staff attachments that were removed and opens new ones at time at
+func recordStaffHistory(tx *sql.Tx, at time.Time) error {
+	if _, err := tx.Exec(closeStaffHistory, at); err != nil {
+		return err
+	}
+	_, err := tx.Exec(openStaffHistory, at)
+	return err
+}
+
+// selectHistory returns history rows ordered by period ordinal, then course and student
+func (rs *Roster) selectHistory(query string, args ...interface{}) (types.Stu415s, error) {
+	s415s, err := rs.selectStu415s(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(s415s, func(i, j int) bool {
+		return s415s[i].Period().Less(s415s[j].Period())
+	})
+	return s415s, nil
+}
+
+// SelectStu415sByTeacherAsOf returns stu415s of the sections the staff member with email was
+// attached to at time t
+func (rs *Roster) SelectStu415sByTeacherAsOf(email string, t time.Time, scope ...types.Scope) (types.Stu415s, error) {
+	t = t.UTC()
+	email = cleanEmail(email)
+	args, err := rs.historyScopeArgs(t, scope)
+	if err != nil {
+		return nil, err
+	}
+	return rs.selectHistory(selectHistoryByTeacherAsOf, append([]interface{}{t, t, email, email, t, t}, args...)...)
+}
+
+// SelectClassesByTeacherAsOf rebuilds the classes a staff member was attached to at time t,
+// with their role in each
+func (rs *Roster) SelectClassesByTeacherAsOf(email string, t time.Time, scope ...types.Scope) ([]*types.Class, error) {
+	s415s, err := rs.SelectStu415sByTeacherAsOf(email, t, scope...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := rs.query(selectStaffRolesAsOf, cleanEmail(email), t.UTC(), t.UTC())
+	if err != nil {
+		return nil, err
+	}
+	roles, err := scanStaffRoles(rows)
+	if err != nil {
+		return nil, err
+	}
+	classes := types.Stu415sToClasses(s415s)
+	setRoles(classes, roles)
+	return classes, nil
+}
+
+// SelectClassByIDAsOf rebuilds a class as it was at time t, or returns sql.ErrNoRows
+// Ids from older sync id schemes are resolved through sync_id_map
+func (rs *Roster) SelectClassByIDAsOf(sid string, t time.Time, scope ...types.Scope) (*types.Class, error) {
+	t = t.UTC()
+	args, err := rs.historyScopeArgs(t, scope)
+	if err != nil {
+		return nil, err
+	}
+	s415s, err := rs.selectHistory(selectHistoryBySIDAsOf, append([]interface{}{t, t, sid}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(s415s) == 0 {
+		newID, err := rs.ResolveSyncID(sid)
+		if err != nil {
+			return nil, err
+		}
+		if newID != sid {
+			if s415s, err = rs.selectHistory(selectHistoryBySIDAsOf, append([]interface{}{t, t, newID}, args...)...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(s415s) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return types.Stu415sToClass(s415s), nil
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectStu415sByTeacherAsOf(t *testing.T) {
+	rs := newTestRoster(t)
+	first := time.Date(2021, 9, 1, 6, 0, 0, 0, time.UTC)
+	rs.Now = func() time.Time { return first }
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+	// Smith's Algebra moves to room 103, Kim leaves it and Park joins it
+	second := first.AddDate(0, 0, 1)
+	rs.Now = func() time.Time { return second }
+	if err := rs.UpdateRosters(readRoster(t, `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,"Garcia, Ana",101,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",103,N
+HS,2021,"Park, Jo",3,F,10,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",103,N
+`), testEmails); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want []string // student and room of each of Smith's rows
+	}{
+		{"before the first load", first.Add(-time.Second), nil},
+		{"first load", first, []string{"Kim, Al 102", "Lee, Bo 102"}},
+		{"between loads", second.Add(-time.Second), []string{"Kim, Al 102", "Lee, Bo 102"}},
+		{"second load", second, []string{"Lee, Bo 103", "Park, Jo 103"}},
+		{"in another time zone", second.In(time.FixedZone("MST", -7*60*60)), []string{"Lee, Bo 103", "Park, Jo 103"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := rs.SelectStu415sByTeacherAsOf("j.smith@aps.edu", tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range rows {
+				got = append(got, s.StudentName+" "+s.Room)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	rows, err := rs.SelectStu415sByTeacher("j.smith@aps.edu")
+	if err != nil || len(rows) == 0 {
+		t.Fatalf("current rows %v, %v", rows, err)
+	}
+	c, err := rs.SelectClassByIDAsOf(rows[0].SyncID, first)
+	if err != nil || len(c.Students) != 2 || c.Room != "102" {
+		t.Errorf("class as of the first load %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,1337 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// memoryDSN selects the in-memory store in NewStore
+const memoryDSN = "memory"
+
+// memorySeedRules are the rules a new Memory starts with, as migration 0005 seeds roster_rules
+var memorySeedRules = types.Rules{
+	{Name: "matthew-advisory", Position: 1, Match: types.RuleMatch{Course: "08%00%"}, Action: types.RuleCoTeacher, Teacher: "matthew.kappus@aps.edu"},
+}
+
+// Memory is a RosterStore kept in process memory for tests and one-shot exports. Updates,
+// history, diffs, checks, rules and overrides behave as they do in Roster; everything is
+// lost when the process exits. An update works on a copy of the roster that replaces it
+// only when every step succeeds
+type Memory struct {
+	Options
+
+	mu           sync.Mutex
+	roster       *memRoster
+	checks       []*types.CheckReport
+	savedRules   map[string]*types.Rule
+	overrides    []*types.Override
+	lastOverride int64
+}
+
+// memRoster is the part of a Memory an update replaces at once
+type memRoster struct {
+	sections     []*memSection
+	students     map[string]memStudent
+	staff        map[string]*types.Staff
+	sectionStaff []*types.SectionStaff
+	history      []*memHistory
+	staffHistory []*memStaffHistory
+	runs         []*types.RosterDiff
+	syncIDs      []*types.SyncIDMapping
+}
+
+// memSection holds the section columns of a Stu415; student columns are empty
+type memSection struct {
+	types.Stu415
+	enrolled []memEnrollment
+}
+
+type memEnrollment struct {
+	permID, prescheduled string
+}
+
+type memStudent struct {
+	name, gender, grade string
+}
+
+// memSectionKey is unique among sections, as in the sections table
+type memSectionKey struct {
+	school, year, syncID, teacher string
+}
+
+// memHistory is an enrollment_history row; to is zero while the row is on the roster
+type memHistory struct {
+	row      types.Stu415
+	from, to time.Time
+}
+
+// memStaffKey is what section_staff_history compares
+type memStaffKey struct {
+	school, year, syncID, email, role string
+}
+
+type memStaffHistory struct {
+	key      memStaffKey
+	from, to time.Time
+}
+
+var _ RosterStore = (*Memory)(nil)
+
+// NewMemory returns an empty in-memory store with the seeded rules
+func NewMemory(opts Options) *Memory {
+	m := &Memory{Options: opts, roster: newMemRoster(), savedRules: make(map[string]*types.Rule)}
+	for _, r := range memorySeedRules {
+		rule := *r
+		m.savedRules[rule.Name] = &rule
+	}
+	return m
+}
+
+func newMemRoster() *memRoster {
+	return &memRoster{students: make(map[string]memStudent), staff: make(map[string]*types.Staff)}
+}
+
+// clone copies everything an update changes
+func (r *memRoster) clone() *memRoster {
+	c := &memRoster{
+		sections:     make([]*memSection, len(r.sections)),
+		students:     make(map[string]memStudent, len(r.students)),
+		staff:        make(map[string]*types.Staff, len(r.staff)),
+		sectionStaff: make([]*types.SectionStaff, len(r.sectionStaff)),
+		history:      make([]*memHistory, len(r.history)),
+		staffHistory: make([]*memStaffHistory, len(r.staffHistory)),
+		runs:         append([]*types.RosterDiff(nil), r.runs...),
+		syncIDs:      append([]*types.SyncIDMapping(nil), r.syncIDs...),
+	}
+	for i, sec := range r.sections {
+		cs := *sec
+		cs.enrolled = append([]memEnrollment(nil), sec.enrolled...)
+		c.sections[i] = &cs
+	}
+	for k, v := range r.students {
+		c.students[k] = v
+	}
+	for k, v := range r.staff {
+		c.staff[k] = v
+	}
+	for i, ss := range r.sectionStaff {
+		cs := *ss
+		c.sectionStaff[i] = &cs
+	}
+	for i, h := range r.history {
+		ch := *h
+		c.history[i] = &ch
+	}
+	for i, h := range r.staffHistory {
+		ch := *h
+		c.staffHistory[i] = &ch
+	}
+	return c
+}
+
+func (sec *memSection) key() memSectionKey {
+	return memSectionKey{sec.OrganizationName, sec.SchoolYear, sec.SyncID, sec.Teacher}
+}
+
+func (sec *memSection) partition() types.Partition {
+	return types.Partition{School: sec.OrganizationName, Year: sec.SchoolYear}
+}
+
+// rows joins the enrollments of the sections keep matches with their students, in roster order
+func (r *memRoster) rows(keep func(*memSection) bool) types.Stu415s {
+	s415s := make(types.Stu415s, 0)
+	for _, sec := range r.sections {
+		if !keep(sec) {
+			continue
+		}
+		for _, e := range sec.enrolled {
+			s := sec.Stu415
+			st := r.students[e.permID]
+			s.StudentName, s.PermID, s.Gender, s.Grade, s.Prescheduled = st.name, e.permID, st.gender, st.grade, e.prescheduled
+			s415s = append(s415s, &s)
+		}
+	}
+	sort.SliceStable(s415s, func(i, j int) bool { return lessStu415(s415s[i], s415s[j]) })
+	return s415s
+}
+
+// lessStu415 orders rows by period number, period, course and student as orderStu415s does
+func lessStu415(a, b *types.Stu415) bool {
+	if pa, pb := a.Period().Num, b.Period().Num; pa != pb {
+		return pa < pb
+	}
+	if a.Per != b.Per {
+		return a.Per < b.Per
+	}
+	if a.CourseIDAndTitle != b.CourseIDAndTitle {
+		return a.CourseIDAndTitle < b.CourseIDAndTitle
+	}
+	return a.StudentName < b.StudentName
+}
+
+// currentYear is the latest school year of school, or of every school if empty
+func (r *memRoster) currentYear(school string) string {
+	year := ""
+	for _, sec := range r.sections {
+		if (school == "" || sec.OrganizationName == school) && sec.SchoolYear > year {
+			year = sec.SchoolYear
+		}
+	}
+	return year
+}
+
+// scope returns an optional scope with its year defaulted as scopeArgs does
+func (r *memRoster) scope(scope []types.Scope) types.Scope {
+	var sc types.Scope
+	if len(scope) > 0 {
+		sc = scope[0]
+	}
+	if sc.Year == "" {
+		sc.Year = r.currentYear(sc.School)
+	}
+	return sc
+}
+
+// historyScope returns an optional scope with its year defaulted as historyScopeArgs does
+func (r *memRoster) historyScope(t time.Time, scope []types.Scope) types.Scope {
+	var sc types.Scope
+	if len(scope) > 0 {
+		sc = scope[0]
+	}
+	if sc.Year == "" {
+		for _, h := range r.history {
+			if validAt(h.from, h.to, t) && (sc.School == "" || h.row.OrganizationName == sc.School) && h.row.SchoolYear > sc.Year {
+				sc.Year = h.row.SchoolYear
+			}
+		}
+	}
+	return sc
+}
+
+// inScope matches scopeClause
+func inScope(sc types.Scope, school, year string) bool {
+	return (sc.School == "" || school == sc.School) && (sc.Year == types.AnyYear || year == sc.Year)
+}
+
+// validAt is true if a history row from from until to was on the roster at t
+func validAt(from, to, t time.Time) bool {
+	return !from.After(t) && (to.IsZero() || to.After(t))
+}
+
+func (r *memRoster) partitions() []types.Partition {
+	seen := make(map[types.Partition]bool)
+	ps := make([]types.Partition, 0)
+	for _, sec := range r.sections {
+		if p := sec.partition(); !seen[p] {
+			seen[p] = true
+			ps = append(ps, p)
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].Year != ps[j].Year {
+			return ps[i].Year > ps[j].Year
+		}
+		return ps[i].School < ps[j].School
+	})
+	return ps
+}
+
+// latestPartitions returns the latest stored year of each school that staged adds a year to,
+// as inLatestPartition matches
+func (r *memRoster) latestPartitions(staged map[types.Partition]bool) map[types.Partition]bool {
+	stored := make(map[types.Partition]bool)
+	for _, sec := range r.sections {
+		stored[sec.partition()] = true
+	}
+	latest := make(map[types.Partition]bool)
+	for p := range staged {
+		if !stored[p] {
+			if year := r.currentYear(p.School); year != "" {
+				latest[types.Partition{School: p.School, Year: year}] = true
+			}
+		}
+	}
+	return latest
+}
+
+func (r *memRoster) sectionExists(school, year, syncID string) bool {
+	for _, sec := range r.sections {
+		if sec.OrganizationName == school && sec.SchoolYear == year && sec.SyncID == syncID {
+			return true
+		}
+	}
+	return false
+}
+
+// merge adds the students, sections and enrollments of s415s as mergeStage does: students
+// are replaced, and existing sections and enrollments are kept
+func (r *memRoster) merge(s415s types.Stu415s) {
+	students := make(map[string]memStudent)
+	for _, s := range s415s {
+		st := students[s.PermID]
+		if s.StudentName > st.name {
+			st.name = s.StudentName
+		}
+		if s.Gender > st.gender {
+			st.gender = s.Gender
+		}
+		if s.Grade > st.grade {
+			st.grade = s.Grade
+		}
+		students[s.PermID] = st
+	}
+	for perm, st := range students {
+		r.students[perm] = st
+	}
+
+	index := make(map[memSectionKey]*memSection, len(r.sections))
+	for _, sec := range r.sections {
+		index[sec.key()] = sec
+	}
+	for _, s := range s415s {
+		k := memSectionKey{s.OrganizationName, s.SchoolYear, s.SyncID, s.Teacher}
+		sec, ok := index[k]
+		if !ok {
+			sec = &memSection{Stu415: *s}
+			sec.StudentName, sec.PermID, sec.Gender, sec.Grade, sec.Prescheduled = "", "", "", "", ""
+			index[k] = sec
+			r.sections = append(r.sections, sec)
+		}
+		if !sec.enrolls(s.PermID) {
+			sec.enrolled = append(sec.enrolled, memEnrollment{s.PermID, s.Prescheduled})
+		}
+	}
+}
+
+func (sec *memSection) enrolls(permID string) bool {
+	for _, e := range sec.enrolled {
+		if e.permID == permID {
+			return true
+		}
+	}
+	return false
+}
+
+// dropUnenrolled removes students no section enrolls, as clearPartitions does
+func (r *memRoster) dropUnenrolled() {
+	enrolled := make(map[string]bool)
+	for _, sec := range r.sections {
+		for _, e := range sec.enrolled {
+			enrolled[e.permID] = true
+		}
+	}
+	for perm := range r.students {
+		if !enrolled[perm] {
+			delete(r.students, perm)
+		}
+	}
+}
+
+// findSectionStaff returns the index of the staff attached to a section by email, or -1
+func (r *memRoster) findSectionStaff(school, year, syncID, email string) int {
+	for i, ss := range r.sectionStaff {
+		if ss.School == school && ss.Year == year && ss.SyncID == syncID && ss.Email == email {
+			return i
+		}
+	}
+	return -1
+}
+
+// addSectionStaff attaches st unless its email is already attached to the section
+func (r *memRoster) addSectionStaff(st types.SectionStaff) {
+	if r.findSectionStaff(st.School, st.Year, st.SyncID, st.Email) < 0 {
+		r.sectionStaff = append(r.sectionStaff, &st)
+	}
+}
+
+// addPrimarySectionStaff attaches every section's teacher as insertPrimarySectionStaff does
+func (r *memRoster) addPrimarySectionStaff() {
+	for _, sec := range r.sections {
+		if sec.Teacher != "" {
+			r.addSectionStaff(types.SectionStaff{School: sec.OrganizationName, Year: sec.SchoolYear, SyncID: sec.SyncID, Email: sec.Teacher, Role: types.RolePrimary, Source: types.StaffFromSynergy})
+		}
+	}
+}
+
+// recordStaffHistory closes staff attachments that were removed and opens new ones at time at
+func (r *memRoster) recordStaffHistory(at time.Time) {
+	current := make(map[memStaffKey]bool, len(r.sectionStaff))
+	for _, ss := range r.sectionStaff {
+		current[memStaffKey{ss.School, ss.Year, ss.SyncID, ss.Email, ss.Role}] = true
+	}
+	open := make(map[memStaffKey]bool)
+	for _, h := range r.staffHistory {
+		if !h.to.IsZero() {
+			continue
+		}
+		if current[h.key] {
+			open[h.key] = true
+		} else {
+			h.to = at
+		}
+	}
+	for _, ss := range r.sectionStaff {
+		k := memStaffKey{ss.School, ss.Year, ss.SyncID, ss.Email, ss.Role}
+		if !open[k] {
+			open[k] = true
+			r.staffHistory = append(r.staffHistory, &memStaffHistory{key: k, from: at})
+		}
+	}
+}
+
+// recordHistory closes history rows of the staged partitions that left the roster and opens
+// rows that joined it at time at
+func (r *memRoster) recordHistory(staged types.Stu415s, parts map[types.Partition]bool, at time.Time) {
+	rows := make(map[types.Stu415]bool, len(staged))
+	for _, s := range staged {
+		rows[*s] = true
+	}
+	open := make(map[types.Stu415]bool)
+	for _, h := range r.history {
+		if !h.to.IsZero() {
+			continue
+		}
+		if !rows[h.row] && parts[types.Partition{School: h.row.OrganizationName, Year: h.row.SchoolYear}] {
+			h.to = at
+			continue
+		}
+		open[h.row] = true
+	}
+	for _, s := range staged {
+		if !open[*s] {
+			open[*s] = true
+			r.history = append(r.history, &memHistory{row: *s, from: at})
+		}
+	}
+	r.recordStaffHistory(at)
+}
+
+// replaceRosters checks and swaps the partitions of u into r and returns their changes,
+// following Roster.replaceRosters
+func (m *Memory) replaceRosters(r *memRoster, u *rosterUpdate) (*types.RosterDiff, error) {
+	staged := u.s415s
+	if len(staged) == 0 {
+		return nil, fmt.Errorf("staged 0 of 0 rows")
+	}
+	missing := 0
+	parts := make(map[types.Partition]bool)
+	for _, s := range staged {
+		if s.PermID == "" || s.SyncID == "" || s.CourseIDAndTitle == "" {
+			missing++
+		}
+		parts[types.Partition{School: s.OrganizationName, Year: s.SchoolYear}] = true
+	}
+	if missing > 0 {
+		return nil, fmt.Errorf("%d staged rows are missing a perm id, sync id or course", missing)
+	}
+
+	live := r.rows(func(sec *memSection) bool { return parts[sec.partition()] })
+	latest := r.latestPartitions(parts)
+	old := r.rows(func(sec *memSection) bool { return parts[sec.partition()] || latest[sec.partition()] })
+	if err := m.checkGuard(old, staged, r.partitions()); err != nil {
+		return nil, err
+	}
+
+	diff := &types.RosterDiff{Run: types.RosterRun{ID: int64(len(r.runs) + 1), At: m.now(), Rows: len(staged)}}
+	if len(live) > 0 {
+		diff.Changes = types.DiffStu415s(live, staged)
+	}
+	r.runs = append(r.runs, diff)
+
+	kept := r.sections[:0:0]
+	for _, sec := range r.sections {
+		if !parts[sec.partition()] {
+			kept = append(kept, sec)
+		}
+	}
+	r.sections = kept
+	for _, st := range u.staff {
+		r.staff[st.Email] = st
+	}
+	r.merge(staged)
+	r.dropUnenrolled()
+
+	ss := r.sectionStaff[:0:0]
+	for _, st := range r.sectionStaff {
+		if st.Source == types.StaffFromImport || (!parts[types.Partition{School: st.School, Year: st.Year}] && r.sectionExists(st.School, st.Year, st.SyncID)) {
+			ss = append(ss, st)
+		}
+	}
+	r.sectionStaff = ss
+	r.addPrimarySectionStaff()
+	for _, st := range u.sectionStaff {
+		rule := *st
+		rule.Email = cleanEmail(rule.Email)
+		r.addSectionStaff(rule)
+	}
+
+	r.recordHistory(staged, parts, diff.Run.At)
+	r.addSyncIDMappings(u.maps)
+	return diff, nil
+}
+
+func (r *memRoster) addSyncIDMappings(maps []*types.SyncIDMapping) {
+	for _, m := range maps {
+		dup := false
+		for _, x := range r.syncIDs {
+			if x.OldID == m.OldID && x.NewID == m.NewID {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			c := *m
+			r.syncIDs = append(r.syncIDs, &c)
+		}
+	}
+}
+
+// UpdateRosters replaces the schools and school years in s415s and updates the staff
+// directory with emails as Roster.UpdateRosters does. A refused or failed update leaves the
+// roster unchanged
+func (m *Memory) UpdateRosters(s415s types.Stu415s, emails [][]string) error {
+	u, err := m.prepareUpdate(m, s415s, emails)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	r := m.roster.clone()
+	diff, err := m.replaceRosters(r, u)
+	if err != nil {
+		m.mu.Unlock()
+		if _, ok := err.(*types.GuardError); ok {
+			return err
+		}
+		return fmt.Errorf("roster update rolled back: %w", err)
+	}
+	m.roster = r
+	m.recordOverrides(u.overrides, diff.Run.At)
+	m.mu.Unlock()
+
+	m.logf("%s", diff.Summary())
+	return nil
+}
+
+// DownloadRosters downloads Stu415s and emails from Synergy and updates the roster with them
+func (m *Memory) DownloadRosters(wait time.Duration, synergyUser, synergyPassword string) error {
+	return downloadRosters(m, wait, synergyUser, synergyPassword)
+}
+
+// CreateNewStu415AndStaffEmails empties the roster and staff
+func (m *Memory) CreateNewStu415AndStaffEmails() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roster.sections = nil
+	m.roster.students = make(map[string]memStudent)
+	m.roster.staff = make(map[string]*types.Staff)
+	return nil
+}
+
+// InsertStu415s adds s415s to the roster and attaches each section's teacher as its primary
+// staff. Bad rows are skipped up to m.MaxBadRows as in UpdateRosters
+func (m *Memory) InsertStu415s(s415s types.Stu415s) error {
+	s415s, err := m.checkRows(s415s)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roster.merge(s415s)
+	m.roster.addPrimarySectionStaff()
+	m.roster.recordStaffHistory(m.now())
+	return nil
+}
+
+// InsertStaff replaces the staff rows for the provided emails
+func (m *Memory) InsertStaff(staff []*types.Staff) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, st := range staff {
+		c := *st
+		m.roster.staff[st.Email] = &c
+	}
+	return nil
+}
+
+// InsertSyncIDMappings records old to new sync ids
+func (m *Memory) InsertSyncIDMappings(maps []*types.SyncIDMapping) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roster.addSyncIDMappings(maps)
+	return nil
+}
+
+// ResolveSyncID returns the current sync id for an id made by an older scheme.
+// Ids without a mapping are returned unchanged
+func (m *Memory) ResolveSyncID(sid string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.roster.resolveSyncID(sid), nil
+}
+
+func (r *memRoster) resolveSyncID(sid string) string {
+	for i := len(r.syncIDs) - 1; i >= 0; i-- {
+		if r.syncIDs[i].OldID == sid {
+			return r.syncIDs[i].NewID
+		}
+	}
+	return sid
+}
+
+// SelectClassesByTeacher returns every class the staff member with email is attached to,
+// with their role in it
+func (m *Memory) SelectClassesByTeacher(email string, scope ...types.Scope) ([]*types.Class, error) {
+	email = cleanEmail(email)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.roster
+	sc := r.scope(scope)
+	roles := make(map[string]string)
+	for _, ss := range r.sectionStaff {
+		if ss.Email == email {
+			roles[staffRoleKey(ss.School, ss.Year, ss.SyncID)] = ss.Role
+		}
+	}
+	s415s := r.rows(func(sec *memSection) bool {
+		role, ok := roles[staffRoleKey(sec.OrganizationName, sec.SchoolYear, sec.SyncID)]
+		return ok && (role != types.RolePrimary || sec.Teacher == email) && inScope(sc, sec.OrganizationName, sec.SchoolYear)
+	})
+	classes := types.Stu415sToClasses(s415s)
+	setRoles(classes, roles)
+	return classes, nil
+}
+
+// SelectCrossListedByTeacher returns a teacher's classes grouped by shared period and room
+func (m *Memory) SelectCrossListedByTeacher(email string, scope ...types.Scope) ([]*types.ClassGroup, error) {
+	classes, err := m.SelectClassesByTeacher(email, scope...)
+	if err != nil {
+		return nil, err
+	}
+	return types.GroupCrossListed(classes), nil
+}
+
+// SelectClassByID takes a syncid and returns a class or sql.ErrNoRows.
+// Ids from older sync id schemes are resolved through the sync id mappings
+func (m *Memory) SelectClassByID(sid string, scope ...types.Scope) (*types.Class, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.roster
+	s415s := r.bySyncID(sid, scope)
+	if newID := r.resolveSyncID(sid); len(s415s) == 0 && newID != sid {
+		s415s = r.bySyncID(newID, scope)
+	}
+	if len(s415s) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return types.Stu415sToClass(s415s), nil
+}
+
+func (r *memRoster) bySyncID(sid string, scope []types.Scope) types.Stu415s {
+	sc := r.scope(scope)
+	return r.rows(func(sec *memSection) bool {
+		return sec.SyncID == sid && inScope(sc, sec.OrganizationName, sec.SchoolYear)
+	})
+}
+
+// selectSections returns the rows of in-scope sections keep matches
+func (m *Memory) selectSections(scope []types.Scope, keep func(*memSection) bool) types.Stu415s {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sc := m.roster.scope(scope)
+	return m.roster.rows(func(sec *memSection) bool {
+		return inScope(sc, sec.OrganizationName, sec.SchoolYear) && keep(sec)
+	})
+}
+
+// SelectStu415sBySyncID returns students by their sync id
+func (m *Memory) SelectStu415sBySyncID(sid string, scope ...types.Scope) (types.Stu415s, error) {
+	return m.selectSections(scope, func(sec *memSection) bool { return sec.SyncID == sid }), nil
+}
+
+// SelectStu415sByTeacherPeriod returns stu415s where provided email is the Teacher during per
+func (m *Memory) SelectStu415sByTeacherPeriod(email, per string, scope ...types.Scope) (types.Stu415s, error) {
+	return m.selectSections(scope, func(sec *memSection) bool { return sec.Teacher == email && sec.Per == per }), nil
+}
+
+// SelectStu415sByTeacher returns stu415s where provided email is the Teacher
+func (m *Memory) SelectStu415sByTeacher(email string, scope ...types.Scope) (types.Stu415s, error) {
+	return m.selectSections(scope, func(sec *memSection) bool { return sec.Teacher == email }), nil
+}
+
+// SelectStu415sBySection returns stu415s in sections with the Synergy section id
+func (m *Memory) SelectStu415sBySection(sectionID string, scope ...types.Scope) (types.Stu415s, error) {
+	return m.selectSections(scope, func(sec *memSection) bool { return sec.SectionID == sectionID }), nil
+}
+
+// selectAll returns every in-scope row
+func (m *Memory) selectAll(scope []types.Scope) types.Stu415s {
+	return m.selectSections(scope, func(*memSection) bool { return true })
+}
+
+// SelectStu415s returns the roster rows matching q. Fields left out of q.Fields are empty
+func (m *Memory) SelectStu415s(q types.Query) (types.Stu415s, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	teacher, student := cleanEmail(q.Teacher), strings.ToLower(q.Student)
+	match := func(s *types.Stu415) bool {
+		return (q.Term == "" || s.Term == q.Term || s.TermName == q.Term) &&
+			(q.Per == "" || s.Per == q.Per) &&
+			(teacher == "" || s.Teacher == teacher) &&
+			(q.Course == "" || s.CourseIDAndTitle == q.Course || s.CourseID() == q.Course) &&
+			(q.SectionID == "" || s.SectionID == q.SectionID) &&
+			(q.Room == "" || s.Room == q.Room) &&
+			(q.Grade == "" || s.Grade == q.Grade) &&
+			(q.Gender == "" || s.Gender == q.Gender) &&
+			(q.Student == "" || s.PermID == types.PermIDEmail(q.Student) || strings.Contains(strings.ToLower(s.StudentName), student))
+	}
+	var rows types.Stu415s
+	for _, s := range m.selectAll([]types.Scope{q.Scope}) {
+		if match(s) {
+			rows = append(rows, s)
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		for _, f := range q.Sort {
+			desc := strings.HasPrefix(f, "-")
+			f = strings.TrimPrefix(f, "-")
+			if f == "per" {
+				if pa, pb := a.Period().Num, b.Period().Num; pa != pb {
+					return (pa < pb) != desc
+				}
+			}
+			if va, vb := *a.Field(f), *b.Field(f); va != vb {
+				return (va < vb) != desc
+			}
+		}
+		if lessStu415(a, b) || lessStu415(b, a) {
+			return lessStu415(a, b)
+		}
+		return a.PermID < b.PermID
+	})
+	if q.Offset >= len(rows) {
+		rows = nil
+	} else {
+		rows = rows[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(rows) {
+		rows = rows[:q.Limit]
+	}
+
+	s415s := make(types.Stu415s, len(rows))
+	for i, s := range rows {
+		p := new(types.Stu415)
+		for _, f := range q.Columns() {
+			*p.Field(f) = *s.Field(f)
+		}
+		s415s[i] = p
+	}
+	return s415s, nil
+}
+
+// SelectScheduleByStudent returns a student with their enrollments ordered by period, or
+// sql.ErrNoRows. permID may be bare or include the @aps.edu domain
+func (m *Memory) SelectScheduleByStudent(permID string, scope ...types.Scope) (*types.Student, error) {
+	permID = types.PermIDEmail(permID)
+	s415s := m.selectSections(scope, func(sec *memSection) bool { return sec.enrolls(permID) })
+	var rows types.Stu415s
+	for _, s := range s415s {
+		if s.PermID == permID {
+			rows = append(rows, s)
+		}
+	}
+	students := types.Stu415sToStudents(rows)
+	if len(students) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return students[0], nil
+}
+
+// SelectAllStudents returns every student with their schedule
+func (m *Memory) SelectAllStudents(scope ...types.Scope) ([]*types.Student, error) {
+	return types.Stu415sToStudents(m.selectAll(scope)), nil
+}
+
+// SelectAllTeachers returns every teacher on the roster with their sections
+func (m *Memory) SelectAllTeachers(scope ...types.Scope) ([]*types.Teacher, error) {
+	return types.Stu415sToTeachers(m.selectAll(scope)), nil
+}
+
+// SelectPartitions returns the schools and years on the roster, newest year first
+func (m *Memory) SelectPartitions() ([]types.Partition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.roster.partitions(), nil
+}
+
+// CurrentYear returns the latest school year on the roster for school, or for every school if empty
+func (m *Memory) CurrentYear(school string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.roster.currentYear(school), nil
+}
+
+// Search finds students, staff and sections whose names, perm ids, emails or course titles
+// contain every term in q, ranking those where terms start a word first as PostgreSQL does
+func (m *Memory) Search(q string) (*types.SearchResults, error) {
+	terms := types.SearchTerms(q)
+	if len(terms) == 0 {
+		return new(types.SearchResults), nil
+	}
+	return loadSearchHits(m, m.searchHits(terms))
+}
+
+// searchHits scans the searchDocuments of the roster for terms
+func (m *Memory) searchHits(terms []string) []types.SearchHit {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.roster
+
+	type document struct {
+		kind, ref, name, ident, course string
+	}
+	var docs []document
+	perms := make([]string, 0, len(r.students))
+	for perm := range r.students {
+		perms = append(perms, perm)
+	}
+	sort.Strings(perms)
+	for _, perm := range perms {
+		docs = append(docs, document{types.SearchStudent, perm, r.students[perm].name, strings.ReplaceAll(perm, types.PermIDDomain, ""), ""})
+	}
+	emails := make([]string, 0, len(r.staff))
+	for email := range r.staff {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	for _, email := range emails {
+		docs = append(docs, document{types.SearchStaff, email, r.staff[email].FormattedName, email, ""})
+	}
+	seen := make(map[document]bool)
+	for _, sec := range r.sections {
+		d := document{types.SearchSection, sec.SyncID, sec.TeacherName, sec.Teacher + " " + sec.SectionID, sec.CourseIDAndTitle}
+		if !seen[d] {
+			seen[d] = true
+			docs = append(docs, d)
+		}
+	}
+
+	var hits []types.SearchHit
+	for _, d := range docs {
+		text := d.name + " " + d.ident + " " + d.course
+		all := true
+		for _, t := range terms {
+			if !strings.Contains(strings.ToLower(text), t) {
+				all = false
+				break
+			}
+		}
+		if all {
+			hits = append(hits, types.SearchHit{Kind: d.kind, Ref: d.ref, Rank: wordStartRank(terms, text)})
+		}
+	}
+	return topHits(hits)
+}
+
+// SelectAnalytics returns section sizes, teacher loads and period counts for the roster,
+// checking section sizes against caps
+func (m *Memory) SelectAnalytics(caps types.SizeCaps, scope ...types.Scope) (*types.Analytics, error) {
+	return types.Analyze(m.selectAll(scope), caps), nil
+}
+
+// SelectRoomReport returns what meets in each room every period, double booked rooms and
+// the rooms free each period
+func (m *Memory) SelectRoomReport(scope ...types.Scope) (*types.RoomReport, error) {
+	return types.RoomUtilization(m.selectAll(scope)), nil
+}
+
+// selectHistory returns the history rows at time t in scope that keep matches, ordered by
+// period ordinal, then course and student
+func (r *memRoster) selectHistory(t time.Time, scope []types.Scope, keep func(*types.Stu415) bool) types.Stu415s {
+	sc := r.historyScope(t, scope)
+	s415s := make(types.Stu415s, 0)
+	for _, h := range r.history {
+		if validAt(h.from, h.to, t) && inScope(sc, h.row.OrganizationName, h.row.SchoolYear) && keep(&h.row) {
+			s := h.row
+			s415s = append(s415s, &s)
+		}
+	}
+	sort.SliceStable(s415s, func(i, j int) bool {
+		a, b := s415s[i], s415s[j]
+		if a.Per != b.Per {
+			return a.Per < b.Per
+		}
+		if a.CourseIDAndTitle != b.CourseIDAndTitle {
+			return a.CourseIDAndTitle < b.CourseIDAndTitle
+		}
+		return a.StudentName < b.StudentName
+	})
+	sort.SliceStable(s415s, func(i, j int) bool {
+		return s415s[i].Period().Less(s415s[j].Period())
+	})
+	return s415s
+}
+
+// staffRolesAsOf maps the sections email was attached to at time t onto their role
+func (r *memRoster) staffRolesAsOf(email string, t time.Time) map[string]string {
+	roles := make(map[string]string)
+	for _, h := range r.staffHistory {
+		if h.key.email == email && validAt(h.from, h.to, t) {
+			roles[staffRoleKey(h.key.school, h.key.year, h.key.syncID)] = h.key.role
+		}
+	}
+	return roles
+}
+
+// SelectStu415sByTeacherAsOf returns stu415s of the sections the staff member with email was
+// attached to at time t
+func (m *Memory) SelectStu415sByTeacherAsOf(email string, t time.Time, scope ...types.Scope) (types.Stu415s, error) {
+	t, email = t.UTC(), cleanEmail(email)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	roles := m.roster.staffRolesAsOf(email, t)
+	return m.roster.selectHistory(t, scope, func(s *types.Stu415) bool {
+		role, ok := roles[staffRoleKey(s.OrganizationName, s.SchoolYear, s.SyncID)]
+		return s.Teacher == email || (ok && role != types.RolePrimary)
+	}), nil
+}
+
+// SelectClassesByTeacherAsOf rebuilds the classes a staff member was attached to at time t,
+// with their role in each
+func (m *Memory) SelectClassesByTeacherAsOf(email string, t time.Time, scope ...types.Scope) ([]*types.Class, error) {
+	s415s, err := m.SelectStu415sByTeacherAsOf(email, t, scope...)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	roles := m.roster.staffRolesAsOf(cleanEmail(email), t.UTC())
+	m.mu.Unlock()
+	classes := types.Stu415sToClasses(s415s)
+	setRoles(classes, roles)
+	return classes, nil
+}
+
+// SelectClassByIDAsOf rebuilds a class as it was at time t, or returns sql.ErrNoRows
+// Ids from older sync id schemes are resolved through the sync id mappings
+func (m *Memory) SelectClassByIDAsOf(sid string, t time.Time, scope ...types.Scope) (*types.Class, error) {
+	t = t.UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.roster
+	bySID := func(sid string) types.Stu415s {
+		return r.selectHistory(t, scope, func(s *types.Stu415) bool { return s.SyncID == sid })
+	}
+	s415s := bySID(sid)
+	if newID := r.resolveSyncID(sid); len(s415s) == 0 && newID != sid {
+		s415s = bySID(newID)
+	}
+	if len(s415s) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return types.Stu415sToClass(s415s), nil
+}
+
+// SelectRosterRuns returns every update run, newest first
+func (m *Memory) SelectRosterRuns() ([]*types.RosterRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := make([]*types.RosterRun, 0, len(m.roster.runs))
+	for i := len(m.roster.runs) - 1; i >= 0; i-- {
+		run := m.roster.runs[i].Run
+		runs = append(runs, &run)
+	}
+	return runs, nil
+}
+
+// SelectDiff returns the changes made by the run with id, or sql.ErrNoRows
+func (m *Memory) SelectDiff(runID int64) (*types.RosterDiff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if runID < 1 || runID > int64(len(m.roster.runs)) {
+		return nil, sql.ErrNoRows
+	}
+	return copyDiff(m.roster.runs[runID-1]), nil
+}
+
+// LatestDiff returns the changes made by the most recent update, or sql.ErrNoRows
+func (m *Memory) LatestDiff() (*types.RosterDiff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.roster.runs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return copyDiff(m.roster.runs[len(m.roster.runs)-1]), nil
+}
+
+func copyDiff(d *types.RosterDiff) *types.RosterDiff {
+	c := &types.RosterDiff{Run: d.Run, Changes: append([]*types.RosterChange(nil), d.Changes...)}
+	types.SortChanges(c.Changes)
+	return c
+}
+
+// recordChecks stores findings from a pass over rows as a new check run. Findings are kept
+// when the update is refused
+func (m *Memory) recordChecks(findings []*types.Finding, rows int) (*types.CheckReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	report := &types.CheckReport{Run: types.CheckRun{ID: int64(len(m.checks) + 1), At: time.Now().UTC(), Rows: rows}, Findings: findings}
+	m.checks = append(m.checks, report)
+	return report, nil
+}
+
+// SelectCheckRuns returns every data quality run, newest first
+func (m *Memory) SelectCheckRuns() ([]*types.CheckRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := make([]*types.CheckRun, 0, len(m.checks))
+	for i := len(m.checks) - 1; i >= 0; i-- {
+		run := m.checks[i].Run
+		runs = append(runs, &run)
+	}
+	return runs, nil
+}
+
+// SelectCheckReport returns the findings of the check run with id, only those of kind if
+// it is set, or sql.ErrNoRows
+func (m *Memory) SelectCheckReport(runID int64, kind string) (*types.CheckReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if runID < 1 || runID > int64(len(m.checks)) {
+		return nil, sql.ErrNoRows
+	}
+	return checkReport(m.checks[runID-1], kind), nil
+}
+
+// LatestCheckReport returns the findings of the most recent download, only those of kind
+// if it is set, or sql.ErrNoRows
+func (m *Memory) LatestCheckReport(kind string) (*types.CheckReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.checks) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return checkReport(m.checks[len(m.checks)-1], kind), nil
+}
+
+func checkReport(r *types.CheckReport, kind string) *types.CheckReport {
+	c := &types.CheckReport{Run: r.Run}
+	for _, f := range r.Findings {
+		if kind == "" || f.Kind == kind {
+			c.Findings = append(c.Findings, f)
+		}
+	}
+	types.SortFindings(c.Findings)
+	return c
+}
+
+// SelectStaffByEmail returns the staff member with the provided email or sql.ErrNoRows
+func (m *Memory) SelectStaffByEmail(email string) (*types.Staff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.roster.staff[cleanEmail(email)]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *st
+	return &c, nil
+}
+
+// SelectStaffByName returns staff whose formatted name matches, ignoring case.
+// If none match, staff listed under that teacher name on a roster are returned
+func (m *Memory) SelectStaffByName(name string) ([]*types.Staff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.roster
+	staff := r.selectStaff(func(st *types.Staff) bool { return strings.EqualFold(st.FormattedName, name) })
+	if len(staff) > 0 {
+		return staff, nil
+	}
+	teachers := make(map[string]bool)
+	for _, sec := range r.sections {
+		if sec.TeacherName == name {
+			teachers[sec.Teacher] = true
+		}
+	}
+	return r.selectStaff(func(st *types.Staff) bool { return teachers[st.Email] }), nil
+}
+
+// SelectAllStaff returns the staff directory ordered by name
+func (m *Memory) SelectAllStaff() ([]*types.Staff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.roster.selectStaff(func(*types.Staff) bool { return true }), nil
+}
+
+// selectStaff returns copies of the staff keep matches ordered by name
+func (r *memRoster) selectStaff(keep func(*types.Staff) bool) []*types.Staff {
+	staff := make([]*types.Staff, 0)
+	for _, st := range r.staff {
+		if keep(st) {
+			c := *st
+			staff = append(staff, &c)
+		}
+	}
+	sort.Slice(staff, func(i, j int) bool {
+		if staff[i].FormattedName != staff[j].FormattedName {
+			return staff[i].FormattedName < staff[j].FormattedName
+		}
+		return staff[i].Email < staff[j].Email
+	})
+	return staff
+}
+
+// ImportSectionStaff attaches staff to sections, replacing their role if already attached.
+// Staff without a School or Year are attached in the current year of each school with the
+// section. Imported staff are kept across updates until deleted
+func (m *Memory) ImportSectionStaff(staff []*types.SectionStaff) error {
+	for _, st := range staff {
+		if !types.ValidRole(st.Role) {
+			return fmt.Errorf("section staff %s in %s: unknown role %q", st.Email, st.SyncID, st.Role)
+		}
+		st.Source = types.StaffFromImport
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.roster.clone()
+	for _, st := range staff {
+		sc := r.scope([]types.Scope{{School: st.School, Year: st.Year}})
+		email := cleanEmail(st.Email)
+		attached := make(map[types.Partition]bool)
+		for _, sec := range r.sections {
+			p := sec.partition()
+			if sec.SyncID != st.SyncID || !inScope(sc, p.School, p.Year) || attached[p] {
+				continue
+			}
+			attached[p] = true
+			if i := r.findSectionStaff(p.School, p.Year, st.SyncID, email); i >= 0 {
+				r.sectionStaff[i].Role, r.sectionStaff[i].Source = st.Role, st.Source
+			} else {
+				r.sectionStaff = append(r.sectionStaff, &types.SectionStaff{School: p.School, Year: p.Year, SyncID: st.SyncID, Email: email, Role: st.Role, Source: st.Source})
+			}
+		}
+		if len(attached) == 0 {
+			return fmt.Errorf("section staff %s in %s: no such section on the roster", st.Email, st.SyncID)
+		}
+	}
+	r.recordStaffHistory(time.Now().UTC())
+	m.roster = r
+	return nil
+}
+
+// DeleteSectionStaff detaches email from the section with syncID, or returns sql.ErrNoRows
+func (m *Memory) DeleteSectionStaff(syncID, email string, scope ...types.Scope) error {
+	email = cleanEmail(email)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.roster
+	sc := r.scope(scope)
+	kept := r.sectionStaff[:0:0]
+	for _, ss := range r.sectionStaff {
+		if !(ss.SyncID == syncID && ss.Email == email && inScope(sc, ss.School, ss.Year)) {
+			kept = append(kept, ss)
+		}
+	}
+	if len(kept) == len(r.sectionStaff) {
+		return sql.ErrNoRows
+	}
+	r.sectionStaff = kept
+	r.recordStaffHistory(time.Now().UTC())
+	return nil
+}
+
+// SelectSectionStaff returns the staff attached to the section with syncID, primary teachers first
+func (m *Memory) SelectSectionStaff(syncID string, scope ...types.Scope) ([]*types.SectionStaff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sc := m.roster.scope(scope)
+	staff := make([]*types.SectionStaff, 0)
+	for _, ss := range m.roster.sectionStaff {
+		if ss.SyncID == syncID && inScope(sc, ss.School, ss.Year) {
+			c := *ss
+			staff = append(staff, &c)
+		}
+	}
+	sort.SliceStable(staff, func(i, j int) bool {
+		a, b := staff[i], staff[j]
+		switch {
+		case a.School != b.School:
+			return a.School < b.School
+		case a.Year != b.Year:
+			return a.Year < b.Year
+		case (a.Role == types.RolePrimary) != (b.Role == types.RolePrimary):
+			return a.Role == types.RolePrimary
+		case a.Role != b.Role:
+			return a.Role < b.Role
+		}
+		return a.Email < b.Email
+	})
+	return staff, nil
+}
+
+// SelectRules returns the saved rules in position order
+func (m *Memory) SelectRules() (types.Rules, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rules := make(types.Rules, 0, len(m.savedRules))
+	for _, r := range m.savedRules {
+		c := *r
+		rules = append(rules, &c)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Position != rules[j].Position {
+			return rules[i].Position < rules[j].Position
+		}
+		return rules[i].Name < rules[j].Name
+	})
+	return rules, nil
+}
+
+// SaveRule adds or replaces a rule
+func (m *Memory) SaveRule(r *types.Rule) error {
+	if err := (types.Rules{r}).Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *r
+	m.savedRules[c.Name] = &c
+	return nil
+}
+
+// DeleteRule removes the named rule
+func (m *Memory) DeleteRule(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.savedRules, name)
+	return nil
+}
+
+// rules returns m.Rules if set, or the saved rules
+func (m *Memory) rules() (types.Rules, error) {
+	if m.Rules != nil {
+		return m.Rules, nil
+	}
+	return m.SelectRules()
+}
+
+// InsertOverride validates and saves o, setting its ID and Created time.
+// Without a Year the override applies to the current year of its School
+func (m *Memory) InsertOverride(o *types.Override) error {
+	o.PermID = types.PermIDEmail(cleanEmail(o.PermID))
+	o.Teacher = cleanEmail(o.Teacher)
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if o.Year == "" {
+		o.Year = m.roster.currentYear(o.School)
+	}
+	if o.Created.IsZero() {
+		o.Created = time.Now().UTC()
+	}
+	m.lastOverride++
+	o.ID = m.lastOverride
+	c := *o
+	m.overrides = append(m.overrides, &c)
+	return nil
+}
+
+// SelectOverrides returns every override, including expired ones, in the order they apply
+func (m *Memory) SelectOverrides() (types.Overrides, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ovs := make(types.Overrides, len(m.overrides))
+	for i, o := range m.overrides {
+		c := *o
+		ovs[i] = &c
+	}
+	return ovs, nil
+}
+
+// SelectOverride returns the override with id, or sql.ErrNoRows
+func (m *Memory) SelectOverride(id int64) (*types.Override, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.overrides {
+		if o.ID == id {
+			c := *o
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// DeleteOverride removes the override with id, or returns sql.ErrNoRows
+func (m *Memory) DeleteOverride(id int64) error {
+	n := m.deleteOverrides(func(o *types.Override) bool { return o.ID == id })
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteExpiredOverrides removes overrides that expired before t and returns how many
+func (m *Memory) DeleteExpiredOverrides(t time.Time) (int64, error) {
+	return m.deleteOverrides(func(o *types.Override) bool { return !o.Expires.IsZero() && !o.Expires.After(t) }), nil
+}
+
+func (m *Memory) deleteOverrides(drop func(*types.Override) bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.overrides[:0:0]
+	for _, o := range m.overrides {
+		if !drop(o) {
+			kept = append(kept, o)
+		}
+	}
+	n := int64(len(m.overrides) - len(kept))
+	m.overrides = kept
+	return n
+}
+
+// recordOverrides stamps overrides the refresh made redundant and clears the stamp from ones applied again
+func (m *Memory) recordOverrides(report *types.OverrideReport, at time.Time) {
+	for _, o := range m.overrides {
+		for _, r := range report.Redundant {
+			if r.ID == o.ID && o.Redundant.IsZero() {
+				o.Redundant = at
+			}
+		}
+		for _, a := range report.Applied {
+			if a.ID == o.ID {
+				o.Redundant = time.Time{}
+			}
+		}
+	}
+}
+
+// MigrationStatus lists no migrations; the in-memory store has no schema
+func (m *Memory) MigrationStatus() ([]*MigrationStatus, error) {
+	return make([]*MigrationStatus, 0), nil
+}
+
+// PendingMigrations is always empty
+func (m *Memory) PendingMigrations() ([]Migration, error) {
+	return nil, nil
+}
+
+// Migrate applies nothing
+func (m *Memory) Migrate() ([]Migration, error) {
+	return nil, nil
+}
+
+// Close does nothing; the roster is dropped with the Memory
+func (m *Memory) Close() error {
+	return nil
+}
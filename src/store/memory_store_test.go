@@ -0,0 +1,71 @@
+package store
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+func TestMemorySearch(t *testing.T) {
+	m := NewMemory(Options{Log: log.New(io.Discard, "", 0)})
+	if err := m.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q                         string
+		students, staff, sections int
+	}{
+		{"lee", 1, 0, 0},
+		{"smi", 0, 1, 1},
+		{"garcia advisory", 0, 0, 1},
+		{"alg", 0, 0, 1},
+		{"nobody", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		res, err := m.Search(tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Students) != tt.students || len(res.Staff) != tt.staff || len(res.Sections) != tt.sections {
+			t.Errorf("Search(%q) = %d students, %d staff, %d sections, want %d, %d, %d", tt.q,
+				len(res.Students), len(res.Staff), len(res.Sections), tt.students, tt.staff, tt.sections)
+		}
+	}
+	res, err := m.Search("lee")
+	if err != nil || len(res.Students) != 1 || len(res.Students[0].Schedule) != 2 {
+		t.Fatalf("Search(lee) = %+v, %v", res, err)
+	}
+}
+
+func TestMemoryImportSectionStaffRollsBack(t *testing.T) {
+	m := NewMemory(Options{Log: log.New(io.Discard, "", 0)})
+	if err := m.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+	classes, err := m.SelectClassesByTeacher("j.smith@aps.edu")
+	if err != nil || len(classes) != 1 {
+		t.Fatalf("classes %v, %v", classes, err)
+	}
+
+	// the second section is missing, so the first is not attached either
+	err = m.ImportSectionStaff([]*types.SectionStaff{
+		{SyncID: classes[0].ID, Email: "co@aps.edu", Role: types.RoleCoTeacher},
+		{SyncID: "missing", Email: "co@aps.edu", Role: types.RoleCoTeacher},
+	})
+	if err == nil {
+		t.Fatal("imported staff into a missing section")
+	}
+	if classes, err := m.SelectClassesByTeacher("co@aps.edu"); err != nil || len(classes) != 0 {
+		t.Fatalf("co-teacher classes after a failed import %v, %v", classes, err)
+	}
+}
+
+func TestOpenMemory(t *testing.T) {
+	if rs, err := Open(memoryDSN); err == nil {
+		rs.Close()
+		t.Fatal("opened the memory store as a database")
+	}
+}
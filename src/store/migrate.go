@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles are applied in version order. Name new files NNNN_description.sql
+// and never edit one that has shipped.
+//
+// PostgreSQL has its own migrations in migrations/postgres.
+//
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+const (
+	createSchemaVersionTable = `CREATE TABLE IF NOT EXISTS schema_version(version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)`
+	selectSchemaVersions     = `SELECT version, applied_at FROM schema_version ORDER BY version`
+	insertSchemaVersion      = `INSERT INTO schema_version(version, name, applied_at) VALUES(?,?,?)`
+
+	selectLegacyStu415Table = `SELECT count(*) FROM sqlite_master WHERE type='table' AND name='stu415'`
+	renameLegacyStu415Table = `ALTER TABLE stu415 RENAME TO stu415_legacy`
+)
+
+// Migration is one embedded schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations returns the embedded SQLite migrations in version order
+func Migrations() ([]Migration, error) {
+	return migrationsIn(sqliteDialect.migrations)
+}
+
+func migrationsIn(dir string) ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ms := make([]Migration, 0, len(entries))
+	seen := make(map[int]string)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ".sql")
+		i := strings.Index(name, "_")
+		if i < 1 {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", e.Name())
+		}
+		v, err := strconv.Atoi(name[:i])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %v", e.Name(), err)
+		}
+		if other, ok := seen[v]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, e.Name(), v)
+		}
+		seen[v] = e.Name()
+		b, err := migrationFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, Migration{Version: v, Name: name[i+1:], SQL: string(b)})
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
+
+// appliedVersions returns applied migration times by version
+func (rs *Roster) appliedVersions() (map[int]time.Time, error) {
+	if _, err := rs.Exec(createSchemaVersionTable); err != nil {
+		return nil, err
+	}
+	rows, err := rs.Query(selectSchemaVersions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus lists every embedded migration for the backend and whether it has been applied
+// It returns an error if the database has a version this build does not know
+func (rs *Roster) MigrationStatus() ([]*MigrationStatus, error) {
+	ms, err := migrationsIn(rs.backend().migrations)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := rs.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]bool, len(ms))
+	status := make([]*MigrationStatus, 0, len(ms))
+	for _, m := range ms {
+		known[m.Version] = true
+		at, ok := applied[m.Version]
+		status = append(status, &MigrationStatus{Migration: m, Applied: ok, AppliedAt: at})
+	}
+	for v := range applied {
+		if !known[v] {
+			return status, fmt.Errorf("database has schema version %d, which is newer than this build", v)
+		}
+	}
+	return status, nil
+}
+
+// PendingMigrations returns migrations that have not been applied
+func (rs *Roster) PendingMigrations() ([]Migration, error) {
+	status, err := rs.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range status {
+		if !s.Applied {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies pending migrations in order, each in its own transaction, and
+// returns the ones applied
+func (rs *Roster) Migrate() ([]Migration, error) {
+	if err := rs.keepLegacyStu415(); err != nil {
+		return nil, err
+	}
+	pending, err := rs.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range pending {
+		if err := rs.applyMigration(m); err != nil {
+			return pending[:i], fmt.Errorf("migration %04d_%s: %v", m.Version, m.Name, err)
+		}
+		rs.logf("applied migration %04d_%s", m.Version, m.Name)
+	}
+	return pending, nil
+}
+
+// applyMigration runs m on one connection. SQLite foreign keys are off while it runs so
+// migrations that rebuild a table do not cascade deletes through its children
+func (rs *Roster) applyMigration(m Migration) error {
+	ctx := context.Background()
+	conn, err := rs.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if rs.backend() == sqliteDialect {
+		if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys=OFF`); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, `PRAGMA foreign_keys=ON`)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(m.SQL); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(insertSchemaVersion, m.Version, m.Name, time.Now().UTC()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// keepLegacyStu415 renames the flat stu415 table from versions before the normalized
+// schema so the stu415 view can take its name. Migration 0013 imports its rows from
+// stu415_legacy
+func (rs *Roster) keepLegacyStu415() error {
+	if rs.backend() != sqliteDialect {
+		return nil
+	}
+	var legacy int
+	if err := rs.QueryRow(selectLegacyStu415Table).Scan(&legacy); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if legacy == 0 {
+		return nil
+	}
+	_, err := rs.Exec(renameLegacyStu415Table)
+	return err
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// baselineStu415 is the flat roster table from before the normalized schema
+const baselineStu415 = `CREATE TABLE IF NOT EXISTS stu415(organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, room, prescheduled, sync_id TEXT)`
+
+func TestMigrateLegacyStu415(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "rosters.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(baselineStu415); err != nil {
+		t.Fatal(err)
+	}
+	// Lee in Garcia's Advisory, which the old CreateMatthewADV copied, and Lee and Kim in Smith's Algebra
+	for _, row := range [][]interface{}{
+		{"HS", "2021", "Lee, Bo", "1", "F", "09", "Year", "1", "YR", "100", "080000 Advisory", "MTWRF", "Garcia, Ana", "101", "N", "s100"},
+		{"HS", "2021", "Lee, Bo", "1", "F", "09", "Year", "1", "YR", "100", "080000 Advisory", "MTWRF", "matthew.kappus@aps.edu", "101", "N", "s100"},
+		{"HS", "2021", "Lee, Bo", "1", "F", "09", "Year", "2A", "YR", "200", "110000 - Algebra", "MTWRF", "Smith, John", "102", "N", "s200"},
+		{"HS", "2021", "Kim, Al", "2", "M", "09", "Year", "2A", "YR", "200", "110000 - Algebra", "MTWRF", "Smith, John", "102", "Y", "s200"},
+	} {
+		if _, err := db.Exec(`INSERT INTO stu415 VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`, row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	rs := openTestRoster(t, dsn)
+	count := func(query string) int {
+		var n int
+		if err := rs.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	for query, want := range map[string]int{
+		`SELECT count(*) FROM stu415_legacy`:                                                       4,
+		`SELECT count(*) FROM students`:                                                            2,
+		`SELECT count(*) FROM sections`:                                                            3,
+		`SELECT count(*) FROM enrollments`:                                                         4,
+		`SELECT count(*) FROM stu415`:                                                              4,
+		`SELECT count(*) FROM section_staff WHERE role='primary'`:                                  3,
+		`SELECT count(*) FROM enrollment_history WHERE valid_to IS NULL`:                           4,
+		`SELECT count(*) FROM section_staff_history WHERE valid_to IS NULL`:                        3,
+		`SELECT count(*) FROM courses WHERE course_id='110000' AND title='Algebra'`:                1,
+		`SELECT count(*) FROM sections WHERE per='2A' AND per_num=2`:                               1,
+		`SELECT count(*) FROM sections WHERE teacher_name='Garcia, Ana'`:                           1,
+		`SELECT count(*) FROM sections WHERE teacher='matthew.kappus@aps.edu' AND teacher_name=''`: 1,
+	} {
+		if n := count(query); n != want {
+			t.Errorf("%s = %d, want %d", query, n, want)
+		}
+	}
+
+	alg, err := rs.SelectClassByID("s200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alg.Teacher != "Smith, John" || len(alg.Students) != 2 {
+		t.Errorf("imported Algebra %+v", alg)
+	}
+	at := time.Now().Add(time.Minute)
+	if past, err := rs.SelectClassByIDAsOf("s200", at); err != nil || len(past.Students) != 2 {
+		t.Errorf("Algebra as of %v: %+v, %v", at, past, err)
+	}
+
+	// a later open finds the import applied and adds nothing
+	rs.Close()
+	rs = openTestRoster(t, dsn)
+	if n := count(`SELECT count(*) FROM enrollments`); n != 4 {
+		t.Errorf("%d enrollments after reopening, want 4", n)
+	}
+}
+
+func TestMigrateLegacyStu415KeepsRoster(t *testing.T) {
+	rs := newTestRoster(t)
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+	// rows left in stu415_legacy are not imported over a roster that has sections
+	if _, err := rs.Exec(`INSERT INTO stu415_legacy VALUES('HS','2021','Park, Jo','3','F','09','Year','3','YR','300','120000 Biology','MTWRF','Smith, John','103','N','s300')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Exec(`DELETE FROM schema_version WHERE version=13`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := rs.QueryRow(`SELECT count(*) FROM enrollments`).Scan(&n); err != nil || n != 3 {
+		t.Errorf("%d enrollments after importing over a roster, want 3: %v", n, err)
+	}
+}
@@ -0,0 +1,67 @@
+-- Normalized roster schema. Synergy rows are split into students, staff, courses,
+-- sections and enrollments; the stu415 view joins them back into the flat report shape.
+-- sections.teacher is not a foreign key to staff because unmatched teachers keep
+-- their Synergy display name.
+
+CREATE TABLE IF NOT EXISTS students(
+	perm_id TEXT PRIMARY KEY,
+	student_name TEXT NOT NULL,
+	gender TEXT NOT NULL DEFAULT '',
+	grade TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS staff(
+	email TEXT PRIMARY KEY,
+	formatted_name TEXT,
+	extra TEXT
+);
+
+CREATE TABLE IF NOT EXISTS courses(
+	course_id_and_title TEXT PRIMARY KEY,
+	course_id TEXT NOT NULL DEFAULT '',
+	title TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS sections(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sync_id TEXT NOT NULL,
+	teacher TEXT NOT NULL,
+	teacher_name TEXT NOT NULL DEFAULT '',
+	section_id TEXT NOT NULL DEFAULT '',
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL REFERENCES courses(course_id_and_title),
+	per TEXT NOT NULL DEFAULT '',
+	per_num INTEGER NOT NULL DEFAULT -1,
+	term TEXT NOT NULL DEFAULT '',
+	term_name TEXT NOT NULL DEFAULT '',
+	meet_days TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	UNIQUE(sync_id, teacher)
+);
+
+CREATE TABLE IF NOT EXISTS enrollments(
+	section INTEGER NOT NULL REFERENCES sections(id) ON DELETE CASCADE,
+	perm_id TEXT NOT NULL REFERENCES students(perm_id),
+	prescheduled TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY(section, perm_id)
+);
+
+CREATE INDEX IF NOT EXISTS sections_teacher ON sections(teacher);
+CREATE INDEX IF NOT EXISTS sections_sync_id ON sections(sync_id);
+CREATE INDEX IF NOT EXISTS sections_section_id ON sections(section_id);
+CREATE INDEX IF NOT EXISTS enrollments_perm_id ON enrollments(perm_id);
+
+-- sync_id_map is kept across updates so links made with older ids keep working
+CREATE TABLE IF NOT EXISTS sync_id_map(
+	old_id TEXT NOT NULL,
+	new_id TEXT NOT NULL,
+	scheme TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY(old_id, new_id)
+);
+
+-- stu415 keeps the flat report shape for existing consumers
+CREATE VIEW IF NOT EXISTS stu415 AS
+SELECT c.organization_name, c.school_year, s.student_name, s.perm_id, s.gender, s.grade, c.term_name, c.per, c.term, c.section_id, c.course_id_and_title, c.meet_days, c.teacher, c.teacher_name, c.room, e.prescheduled, c.sync_id
+FROM enrollments e JOIN sections c ON c.id=e.section JOIN students s ON s.perm_id=e.perm_id;
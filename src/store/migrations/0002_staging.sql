@@ -0,0 +1,31 @@
+-- Updates load rows into the stage tables, validate them, and replace the
+-- live roster from them in the same transaction.
+
+CREATE TABLE IF NOT EXISTS stage_stu415(
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	perm_id TEXT NOT NULL DEFAULT '',
+	gender TEXT NOT NULL DEFAULT '',
+	grade TEXT NOT NULL DEFAULT '',
+	term_name TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	term TEXT NOT NULL DEFAULT '',
+	section_id TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL DEFAULT '',
+	meet_days TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	teacher_name TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	prescheduled TEXT NOT NULL DEFAULT '',
+	sync_id TEXT NOT NULL DEFAULT '',
+	course_id TEXT NOT NULL DEFAULT '',
+	course_title TEXT NOT NULL DEFAULT '',
+	per_num INTEGER NOT NULL DEFAULT -1
+);
+
+CREATE TABLE IF NOT EXISTS stage_staff(
+	email TEXT PRIMARY KEY,
+	formatted_name TEXT,
+	extra TEXT
+);
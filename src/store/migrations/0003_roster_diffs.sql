@@ -0,0 +1,22 @@
+-- Each update records a run and the changes it made to the previous roster.
+
+CREATE TABLE IF NOT EXISTS roster_runs(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	at TIMESTAMP NOT NULL,
+	rows INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS roster_changes(
+	run_id INTEGER NOT NULL REFERENCES roster_runs(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	sync_id TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	perm_id TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	old_value TEXT NOT NULL DEFAULT '',
+	new_value TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS roster_changes_run ON roster_changes(run_id);
@@ -0,0 +1,32 @@
+-- enrollment_history keeps every version of every stu415 row with the updates
+-- that first and last saw it. valid_to is NULL for rows on the current roster.
+
+CREATE TABLE IF NOT EXISTS enrollment_history(
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	perm_id TEXT NOT NULL DEFAULT '',
+	gender TEXT NOT NULL DEFAULT '',
+	grade TEXT NOT NULL DEFAULT '',
+	term_name TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	term TEXT NOT NULL DEFAULT '',
+	section_id TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL DEFAULT '',
+	meet_days TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	teacher_name TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	prescheduled TEXT NOT NULL DEFAULT '',
+	sync_id TEXT NOT NULL DEFAULT '',
+	valid_from TIMESTAMP NOT NULL,
+	valid_to TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS enrollment_history_teacher ON enrollment_history(teacher, valid_from);
+CREATE INDEX IF NOT EXISTS enrollment_history_sync_id ON enrollment_history(sync_id, valid_from);
+CREATE INDEX IF NOT EXISTS enrollment_history_open ON enrollment_history(perm_id, sync_id) WHERE valid_to IS NULL;
+
+-- Rows already on the roster start their history now
+INSERT INTO enrollment_history(organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, teacher_name, room, prescheduled, sync_id, valid_from)
+SELECT DISTINCT organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, teacher_name, room, prescheduled, sync_id, CURRENT_TIMESTAMP FROM stu415;
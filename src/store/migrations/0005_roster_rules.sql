@@ -0,0 +1,19 @@
+-- roster_rules change rows after every update. Patterns use LIKE syntax.
+-- The seeded rule replaces the hardcoded CreateMatthewADV.
+
+CREATE TABLE IF NOT EXISTS roster_rules(
+	name TEXT PRIMARY KEY,
+	position INTEGER NOT NULL,
+	course TEXT NOT NULL DEFAULT '',
+	school TEXT NOT NULL DEFAULT '',
+	grade TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	teacher_match TEXT NOT NULL DEFAULT '',
+	action TEXT NOT NULL,
+	teacher TEXT NOT NULL DEFAULT '',
+	title TEXT NOT NULL DEFAULT '',
+	enabled INTEGER NOT NULL DEFAULT 1
+);
+
+INSERT OR IGNORE INTO roster_rules(name, position, course, action, teacher)
+VALUES('matthew-advisory', 1, '08%00%', 'co-teacher', 'matthew.kappus@aps.edu');
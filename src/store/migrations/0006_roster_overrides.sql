@@ -0,0 +1,18 @@
+-- roster_overrides are local adds, removes and teacher swaps applied on top of every refresh.
+-- redundant_at is set by the first refresh whose Synergy data already matches the override.
+
+CREATE TABLE IF NOT EXISTS roster_overrides(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL CHECK(kind IN ('add', 'remove', 'swap')),
+	sync_id TEXT NOT NULL,
+	perm_id TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	author TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP,
+	redundant_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS roster_overrides_sync_id ON roster_overrides(sync_id);
@@ -0,0 +1,17 @@
+-- section_staff attaches any number of staff to a section with a role. Rows from Synergy and
+-- roster rules are rebuilt on every update; imported rows are kept until deleted.
+
+CREATE TABLE IF NOT EXISTS section_staff(
+	sync_id TEXT NOT NULL,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL CHECK(role IN ('primary', 'co-teacher', 'aide', 'observer')),
+	source TEXT NOT NULL CHECK(source IN ('synergy', 'rule', 'import')),
+	PRIMARY KEY(sync_id, email)
+);
+
+CREATE INDEX IF NOT EXISTS section_staff_email ON section_staff(email);
+
+INSERT OR IGNORE INTO section_staff(sync_id, email, role, source)
+SELECT DISTINCT sync_id, teacher, 'primary', 'synergy' FROM sections;
+
+ALTER TABLE roster_rules ADD COLUMN role TEXT NOT NULL DEFAULT '';
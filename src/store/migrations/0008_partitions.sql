@@ -0,0 +1,45 @@
+-- Rosters are partitioned by school and school year. An update replaces only the
+-- partitions listed in stage_partitions, so sections are unique within a partition and
+-- the same sync id may appear in several years.
+
+CREATE TABLE IF NOT EXISTS stage_partitions(
+	organization_name TEXT NOT NULL,
+	school_year TEXT NOT NULL,
+	PRIMARY KEY(organization_name, school_year)
+);
+
+DROP VIEW IF EXISTS stu415;
+
+CREATE TABLE sections_partitioned(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sync_id TEXT NOT NULL,
+	teacher TEXT NOT NULL,
+	teacher_name TEXT NOT NULL DEFAULT '',
+	section_id TEXT NOT NULL DEFAULT '',
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL REFERENCES courses(course_id_and_title),
+	per TEXT NOT NULL DEFAULT '',
+	per_num INTEGER NOT NULL DEFAULT -1,
+	term TEXT NOT NULL DEFAULT '',
+	term_name TEXT NOT NULL DEFAULT '',
+	meet_days TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	UNIQUE(organization_name, school_year, sync_id, teacher)
+);
+
+INSERT INTO sections_partitioned(id, sync_id, teacher, teacher_name, section_id, organization_name, school_year, course_id_and_title, per, per_num, term, term_name, meet_days, room)
+SELECT id, sync_id, teacher, teacher_name, section_id, organization_name, school_year, course_id_and_title, per, per_num, term, term_name, meet_days, room FROM sections;
+
+DROP TABLE sections;
+ALTER TABLE sections_partitioned RENAME TO sections;
+
+CREATE INDEX IF NOT EXISTS sections_teacher ON sections(teacher);
+CREATE INDEX IF NOT EXISTS sections_sync_id ON sections(sync_id);
+CREATE INDEX IF NOT EXISTS sections_section_id ON sections(section_id);
+CREATE INDEX IF NOT EXISTS sections_partition ON sections(school_year, organization_name);
+CREATE INDEX IF NOT EXISTS enrollment_history_partition ON enrollment_history(school_year, organization_name);
+
+CREATE VIEW IF NOT EXISTS stu415 AS
+SELECT c.organization_name, c.school_year, s.student_name, s.perm_id, s.gender, s.grade, c.term_name, c.per, c.term, c.section_id, c.course_id_and_title, c.meet_days, c.teacher, c.teacher_name, c.room, e.prescheduled, c.sync_id
+FROM enrollments e JOIN sections c ON c.id=e.section JOIN students s ON s.perm_id=e.perm_id;
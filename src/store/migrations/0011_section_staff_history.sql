@@ -0,0 +1,19 @@
+-- section_staff_history keeps every staff attachment with the times it was first and last
+-- seen, so past rosters include co-teachers and other non-primary staff. valid_to is NULL
+-- for current attachments.
+
+CREATE TABLE IF NOT EXISTS section_staff_history(
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	sync_id TEXT NOT NULL,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL,
+	valid_from TIMESTAMP NOT NULL,
+	valid_to TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS section_staff_history_email ON section_staff_history(email, valid_from);
+
+-- Current attachments start their history now
+INSERT INTO section_staff_history(organization_name, school_year, sync_id, email, role, valid_from)
+SELECT organization_name, school_year, sync_id, email, role, CURRENT_TIMESTAMP FROM section_staff;
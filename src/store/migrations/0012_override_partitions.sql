@@ -0,0 +1,5 @@
+-- A sync id may repeat across schools and years, so overrides name the partition of their
+-- section. Existing overrides keep '' and match every school and year as before.
+
+ALTER TABLE roster_overrides ADD COLUMN organization_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE roster_overrides ADD COLUMN school_year TEXT NOT NULL DEFAULT '';
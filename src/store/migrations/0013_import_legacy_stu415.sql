@@ -0,0 +1,70 @@
+-- Databases from before the normalized schema kept one flat stu415 table, which Migrate
+-- renames to stu415_legacy. Its rows are imported into an empty roster so the last roster
+-- survives the upgrade; a roster that already has sections is left alone. The legacy table
+-- is kept, and created empty for databases that never had one.
+
+CREATE TABLE IF NOT EXISTS stu415_legacy(organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, room, prescheduled, sync_id TEXT);
+
+CREATE TEMP TABLE legacy_stu415 AS
+SELECT
+	coalesce(organization_name, '') AS organization_name,
+	coalesce(school_year, '') AS school_year,
+	coalesce(student_name, '') AS student_name,
+	trim(coalesce(perm_id, '')) AS perm_id,
+	coalesce(gender, '') AS gender,
+	coalesce(grade, '') AS grade,
+	coalesce(term_name, '') AS term_name,
+	coalesce(per, '') AS per,
+	CASE WHEN substr(trim(per), 1, 1) BETWEEN '0' AND '9' THEN CAST(trim(per) AS INTEGER) ELSE -1 END AS per_num,
+	coalesce(term, '') AS term,
+	coalesce(section_id, '') AS section_id,
+	trim(course_id_and_title) AS course_id_and_title,
+	coalesce(meet_days, '') AS meet_days,
+	teacher,
+	CASE WHEN teacher LIKE '%@%' THEN '' ELSE teacher END AS teacher_name,
+	coalesce(room, '') AS room,
+	coalesce(prescheduled, '') AS prescheduled,
+	coalesce(nullif(sync_id, ''), section_id) AS sync_id
+FROM stu415_legacy
+WHERE coalesce(teacher, '')<>'' AND coalesce(nullif(sync_id, ''), section_id, '')<>'' AND coalesce(trim(course_id_and_title), '')<>''
+AND NOT EXISTS (SELECT 1 FROM sections);
+
+-- Course codes are split from titles at " - " or the first space, as Stu415.CourseID does
+INSERT OR IGNORE INTO courses(course_id_and_title, course_id, title)
+SELECT DISTINCT course_id_and_title,
+	CASE
+		WHEN instr(course_id_and_title, ' - ')>1 THEN trim(substr(course_id_and_title, 1, instr(course_id_and_title, ' - ')-1))
+		WHEN instr(course_id_and_title, ' ')>1 THEN substr(course_id_and_title, 1, instr(course_id_and_title, ' ')-1)
+		ELSE course_id_and_title
+	END,
+	CASE
+		WHEN instr(course_id_and_title, ' - ')>1 THEN trim(substr(course_id_and_title, instr(course_id_and_title, ' - ')+3))
+		WHEN instr(course_id_and_title, ' ')>1 THEN trim(substr(course_id_and_title, instr(course_id_and_title, ' ')+1))
+		ELSE ''
+	END
+FROM legacy_stu415;
+
+INSERT OR IGNORE INTO students(perm_id, student_name, gender, grade)
+SELECT perm_id, max(student_name), max(gender), max(grade) FROM legacy_stu415 WHERE perm_id<>'' GROUP BY perm_id;
+
+INSERT OR IGNORE INTO sections(sync_id, teacher, teacher_name, section_id, organization_name, school_year, course_id_and_title, per, per_num, term, term_name, meet_days, room)
+SELECT sync_id, teacher, max(teacher_name), max(section_id), organization_name, school_year, max(course_id_and_title), max(per), max(per_num), max(term), max(term_name), max(meet_days), max(room)
+FROM legacy_stu415 GROUP BY organization_name, school_year, sync_id, teacher;
+
+INSERT OR IGNORE INTO enrollments(section, perm_id, prescheduled)
+SELECT c.id, g.perm_id, max(g.prescheduled) FROM legacy_stu415 g
+JOIN sections c ON c.organization_name=g.organization_name AND c.school_year=g.school_year AND c.sync_id=g.sync_id AND c.teacher=g.teacher
+WHERE g.perm_id<>'' GROUP BY c.id, g.perm_id;
+
+INSERT OR IGNORE INTO section_staff(organization_name, school_year, sync_id, email, role, source)
+SELECT DISTINCT organization_name, school_year, sync_id, teacher, 'primary', 'synergy' FROM legacy_stu415;
+
+-- Imported rows start their history now, as rows on the roster did in 0004 and 0011
+INSERT INTO enrollment_history(organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, teacher_name, room, prescheduled, sync_id, valid_from)
+SELECT DISTINCT v.organization_name, v.school_year, v.student_name, v.perm_id, v.gender, v.grade, v.term_name, v.per, v.term, v.section_id, v.course_id_and_title, v.meet_days, v.teacher, v.teacher_name, v.room, v.prescheduled, v.sync_id, CURRENT_TIMESTAMP
+FROM stu415 v WHERE EXISTS (SELECT 1 FROM legacy_stu415);
+
+INSERT INTO section_staff_history(organization_name, school_year, sync_id, email, role, valid_from)
+SELECT organization_name, school_year, sync_id, email, role, CURRENT_TIMESTAMP FROM section_staff WHERE EXISTS (SELECT 1 FROM legacy_stu415);
+
+DROP TABLE legacy_stu415;
@@ -0,0 +1,193 @@
+-- PostgreSQL schema equivalent to SQLite migrations 0001 through 0008. New schema changes
+-- need a migration here as well as in the SQLite directory.
+-- Foreign keys are deferred because updates replace parents and children in one transaction.
+
+CREATE TABLE IF NOT EXISTS students(
+	perm_id TEXT PRIMARY KEY,
+	student_name TEXT NOT NULL,
+	gender TEXT NOT NULL DEFAULT '',
+	grade TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS staff(
+	email TEXT PRIMARY KEY,
+	formatted_name TEXT,
+	extra TEXT
+);
+
+CREATE TABLE IF NOT EXISTS courses(
+	course_id_and_title TEXT PRIMARY KEY,
+	course_id TEXT NOT NULL DEFAULT '',
+	title TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS sections(
+	id SERIAL PRIMARY KEY,
+	sync_id TEXT NOT NULL,
+	teacher TEXT NOT NULL,
+	teacher_name TEXT NOT NULL DEFAULT '',
+	section_id TEXT NOT NULL DEFAULT '',
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL REFERENCES courses(course_id_and_title) DEFERRABLE INITIALLY DEFERRED,
+	per TEXT NOT NULL DEFAULT '',
+	per_num INTEGER NOT NULL DEFAULT -1,
+	term TEXT NOT NULL DEFAULT '',
+	term_name TEXT NOT NULL DEFAULT '',
+	meet_days TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	UNIQUE(organization_name, school_year, sync_id, teacher)
+);
+
+CREATE TABLE IF NOT EXISTS enrollments(
+	section INTEGER NOT NULL REFERENCES sections(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+	perm_id TEXT NOT NULL REFERENCES students(perm_id) DEFERRABLE INITIALLY DEFERRED,
+	prescheduled TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY(section, perm_id)
+);
+
+CREATE INDEX IF NOT EXISTS sections_teacher ON sections(teacher);
+CREATE INDEX IF NOT EXISTS sections_sync_id ON sections(sync_id);
+CREATE INDEX IF NOT EXISTS sections_section_id ON sections(section_id);
+CREATE INDEX IF NOT EXISTS sections_partition ON sections(school_year, organization_name);
+CREATE INDEX IF NOT EXISTS enrollments_perm_id ON enrollments(perm_id);
+
+CREATE TABLE IF NOT EXISTS sync_id_map(
+	old_id TEXT NOT NULL,
+	new_id TEXT NOT NULL,
+	scheme TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY(old_id, new_id)
+);
+
+CREATE OR REPLACE VIEW stu415 AS
+SELECT c.organization_name, c.school_year, s.student_name, s.perm_id, s.gender, s.grade, c.term_name, c.per, c.term, c.section_id, c.course_id_and_title, c.meet_days, c.teacher, c.teacher_name, c.room, e.prescheduled, c.sync_id
+FROM enrollments e JOIN sections c ON c.id=e.section JOIN students s ON s.perm_id=e.perm_id;
+
+CREATE TABLE IF NOT EXISTS stage_stu415(
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	perm_id TEXT NOT NULL DEFAULT '',
+	gender TEXT NOT NULL DEFAULT '',
+	grade TEXT NOT NULL DEFAULT '',
+	term_name TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	term TEXT NOT NULL DEFAULT '',
+	section_id TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL DEFAULT '',
+	meet_days TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	teacher_name TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	prescheduled TEXT NOT NULL DEFAULT '',
+	sync_id TEXT NOT NULL DEFAULT '',
+	course_id TEXT NOT NULL DEFAULT '',
+	course_title TEXT NOT NULL DEFAULT '',
+	per_num INTEGER NOT NULL DEFAULT -1
+);
+
+CREATE TABLE IF NOT EXISTS stage_staff(
+	email TEXT PRIMARY KEY,
+	formatted_name TEXT,
+	extra TEXT
+);
+
+CREATE TABLE IF NOT EXISTS stage_partitions(
+	organization_name TEXT NOT NULL,
+	school_year TEXT NOT NULL,
+	PRIMARY KEY(organization_name, school_year)
+);
+
+CREATE TABLE IF NOT EXISTS roster_runs(
+	id SERIAL PRIMARY KEY,
+	at TIMESTAMP NOT NULL,
+	rows INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS roster_changes(
+	run_id INTEGER NOT NULL REFERENCES roster_runs(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	sync_id TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	perm_id TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	old_value TEXT NOT NULL DEFAULT '',
+	new_value TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS roster_changes_run ON roster_changes(run_id);
+
+CREATE TABLE IF NOT EXISTS enrollment_history(
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	perm_id TEXT NOT NULL DEFAULT '',
+	gender TEXT NOT NULL DEFAULT '',
+	grade TEXT NOT NULL DEFAULT '',
+	term_name TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	term TEXT NOT NULL DEFAULT '',
+	section_id TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL DEFAULT '',
+	meet_days TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	teacher_name TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	prescheduled TEXT NOT NULL DEFAULT '',
+	sync_id TEXT NOT NULL DEFAULT '',
+	valid_from TIMESTAMP NOT NULL,
+	valid_to TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS enrollment_history_teacher ON enrollment_history(teacher, valid_from);
+CREATE INDEX IF NOT EXISTS enrollment_history_sync_id ON enrollment_history(sync_id, valid_from);
+CREATE INDEX IF NOT EXISTS enrollment_history_open ON enrollment_history(perm_id, sync_id) WHERE valid_to IS NULL;
+CREATE INDEX IF NOT EXISTS enrollment_history_partition ON enrollment_history(school_year, organization_name);
+
+CREATE TABLE IF NOT EXISTS roster_rules(
+	name TEXT PRIMARY KEY,
+	position INTEGER NOT NULL,
+	course TEXT NOT NULL DEFAULT '',
+	school TEXT NOT NULL DEFAULT '',
+	grade TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	teacher_match TEXT NOT NULL DEFAULT '',
+	action TEXT NOT NULL,
+	teacher TEXT NOT NULL DEFAULT '',
+	title TEXT NOT NULL DEFAULT '',
+	enabled INTEGER NOT NULL DEFAULT 1,
+	role TEXT NOT NULL DEFAULT ''
+);
+
+INSERT INTO roster_rules(name, position, course, action, teacher)
+VALUES('matthew-advisory', 1, '08%00%', 'co-teacher', 'matthew.kappus@aps.edu')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS roster_overrides(
+	id SERIAL PRIMARY KEY,
+	kind TEXT NOT NULL CHECK(kind IN ('add', 'remove', 'swap')),
+	sync_id TEXT NOT NULL,
+	perm_id TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	author TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP,
+	redundant_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS roster_overrides_sync_id ON roster_overrides(sync_id);
+
+CREATE TABLE IF NOT EXISTS section_staff(
+	sync_id TEXT NOT NULL,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL CHECK(role IN ('primary', 'co-teacher', 'aide', 'observer')),
+	source TEXT NOT NULL CHECK(source IN ('synergy', 'rule', 'import')),
+	PRIMARY KEY(sync_id, email)
+);
+
+CREATE INDEX IF NOT EXISTS section_staff_email ON section_staff(email);
@@ -0,0 +1,25 @@
+-- Each download gets a data quality pass. Its findings are kept per run, even when the
+-- update that follows is refused, so they can be reviewed and exported later.
+
+CREATE TABLE IF NOT EXISTS check_runs(
+	id SERIAL PRIMARY KEY,
+	at TIMESTAMP NOT NULL,
+	rows INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS check_findings(
+	run_id INTEGER NOT NULL REFERENCES check_runs(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	sync_id TEXT NOT NULL DEFAULT '',
+	course_id_and_title TEXT NOT NULL DEFAULT '',
+	per TEXT NOT NULL DEFAULT '',
+	term TEXT NOT NULL DEFAULT '',
+	teacher TEXT NOT NULL DEFAULT '',
+	perm_id TEXT NOT NULL DEFAULT '',
+	student_name TEXT NOT NULL DEFAULT '',
+	detail TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS check_findings_run ON check_findings(run_id, kind);
@@ -0,0 +1,33 @@
+-- A sync id may repeat across schools and years, so section staff are keyed by partition
+-- as well as sync id, and roster changes record the partition of their section. Existing
+-- staff are attached to every partition with their sync id; imports for sections no longer
+-- on the roster are kept without one.
+
+CREATE TABLE section_staff_partitioned(
+	organization_name TEXT NOT NULL DEFAULT '',
+	school_year TEXT NOT NULL DEFAULT '',
+	sync_id TEXT NOT NULL,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL CHECK(role IN ('primary', 'co-teacher', 'aide', 'observer')),
+	source TEXT NOT NULL CHECK(source IN ('synergy', 'rule', 'import')),
+	PRIMARY KEY(organization_name, school_year, sync_id, email)
+);
+
+INSERT INTO section_staff_partitioned(organization_name, school_year, sync_id, email, role, source)
+SELECT DISTINCT c.organization_name, c.school_year, ss.sync_id, ss.email, ss.role, ss.source
+FROM section_staff ss JOIN sections c ON c.sync_id=ss.sync_id
+WHERE ss.role<>'primary' OR c.teacher=ss.email
+ON CONFLICT DO NOTHING;
+
+INSERT INTO section_staff_partitioned(sync_id, email, role, source)
+SELECT sync_id, email, role, source FROM section_staff ss
+WHERE source='import' AND NOT EXISTS (SELECT 1 FROM sections c WHERE c.sync_id=ss.sync_id)
+ON CONFLICT DO NOTHING;
+
+DROP TABLE section_staff;
+ALTER TABLE section_staff_partitioned RENAME TO section_staff;
+
+CREATE INDEX IF NOT EXISTS section_staff_email ON section_staff(email);
+
+ALTER TABLE roster_changes ADD COLUMN organization_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE roster_changes ADD COLUMN school_year TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+const (
+	overrideColumns        = `id, kind, organization_name, school_year, sync_id, perm_id, student_name, teacher, author, reason, created_at, expires_at, redundant_at`
+	insertOverride         = `INSERT INTO roster_overrides(kind, organization_name, school_year, sync_id, perm_id, student_name, teacher, author, reason, created_at, expires_at) VALUES(?,?,?,?,?,?,?,?,?,?,?) RETURNING id`
+	selectOverrides        = `SELECT ` + overrideColumns + ` FROM roster_overrides ORDER BY id`
+	selectOverride         = `SELECT ` + overrideColumns + ` FROM roster_overrides WHERE id=?`
+	deleteOverride         = `DELETE FROM roster_overrides WHERE id=?`
+	markRedundant          = `UPDATE roster_overrides SET redundant_at=coalesce(redundant_at, ?) WHERE id=?`
+	clearRedundant         = `UPDATE roster_overrides SET redundant_at=NULL WHERE id=?`
+	deleteExpiredOverrides = `DELETE FROM roster_overrides WHERE expires_at IS NOT NULL AND expires_at<=?`
+)
+
+// InsertOverride validates and saves o, setting its ID and Created time.
+// Without a Year the override applies to the current year of its School
+func (rs *Roster) InsertOverride(o *types.Override) error {
+	o.PermID = types.PermIDEmail(cleanEmail(o.PermID))
+	o.Teacher = cleanEmail(o.Teacher)
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if o.Year == "" {
+		var err error
+		if o.Year, err = rs.CurrentYear(o.School); err != nil {
+			return err
+		}
+	}
+	if o.Created.IsZero() {
+		o.Created = time.Now().UTC()
+	}
+	return rs.QueryRow(insertOverride, o.Kind, o.School, o.Year, o.SyncID, o.PermID, o.StudentName, o.Teacher, o.Author, o.Reason, o.Created, nullTime(o.Expires)).Scan(&o.ID)
+}
+
+// SelectOverrides returns every override, including expired ones, in the order they apply
+func (rs *Roster) SelectOverrides() (types.Overrides, error) {
+	rows, err := rs.query(selectOverrides)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ovs := make(types.Overrides, 0)
+	for rows.Next() {
+		o, err := scanOverride(rows)
+		if err != nil {
+			return nil, err
+		}
+		ovs = append(ovs, o)
+	}
+	return ovs, rows.Err()
+}
+
+// SelectOverride returns the override with id, or sql.ErrNoRows
+func (rs *Roster) SelectOverride(id int64) (*types.Override, error) {
+	return scanOverride(rs.QueryRow(selectOverride, id))
+}
+
+// DeleteOverride removes the override with id
+func (rs *Roster) DeleteOverride(id int64) error {
+	res, err := rs.Exec(deleteOverride, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteExpiredOverrides removes overrides that expired before t and returns how many
+func (rs *Roster) DeleteExpiredOverrides(t time.Time) (int64, error) {
+	res, err := rs.Exec(deleteExpiredOverrides, t.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// recordOverrides stamps overrides the refresh made redundant and clears the stamp from ones applied again
+func recordOverrides(tx *sql.Tx, report *types.OverrideReport, at time.Time) error {
+	for _, o := range report.Redundant {
+		if _, err := tx.Exec(markRedundant, at, o.ID); err != nil {
+			return err
+		}
+	}
+	for _, o := range report.Applied {
+		if _, err := tx.Exec(clearRedundant, o.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanOverride(row interface{ Scan(...interface{}) error }) (*types.Override, error) {
+	o := new(types.Override)
+	var expires, redundant sql.NullTime
+	if err := row.Scan(&o.ID, &o.Kind, &o.School, &o.Year, &o.SyncID, &o.PermID, &o.StudentName, &o.Teacher, &o.Author, &o.Reason, &o.Created, &expires, &redundant); err != nil {
+		return nil, err
+	}
+	o.Expires, o.Redundant = expires.Time, redundant.Time
+	return o, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+const (
+	insertCheckRun       = `INSERT INTO check_runs(at, rows) VALUES(?,?) RETURNING id`
+	insertCheckFinding   = `INSERT INTO check_findings(run_id, kind, organization_name, school_year, sync_id, course_id_and_title, per, term, teacher, perm_id, student_name, detail) VALUES(?,?,?,?,?,?,?,?,?,?,?,?)`
+	selectCheckRuns      = `SELECT id, at, rows FROM check_runs ORDER BY id DESC`
+	selectCheckRun       = `SELECT id, at, rows FROM check_runs WHERE id=?`
+	selectLatestCheckRun = `SELECT id, at, rows FROM check_runs ORDER BY id DESC LIMIT 1`
+	selectCheckFindings  = `SELECT kind, organization_name, school_year, sync_id, course_id_and_title, per, term, teacher, perm_id, student_name, detail FROM check_findings WHERE run_id=? AND (CAST(? AS TEXT)='' OR kind=?)`
+)
+
+// recordChecks stores findings from a pass over rows as a new check run. It commits on its
+// own so findings are kept when the update is refused
+func (rs *Roster) recordChecks(findings []*types.Finding, rows int) (*types.CheckReport, error) {
+	report := &types.CheckReport{Run: types.CheckRun{At: time.Now().UTC(), Rows: rows}, Findings: findings}
+	tx, err := rs.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(insertCheckRun, report.Run.At, report.Run.Rows).Scan(&report.Run.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	stmt, err := tx.Prepare(insertCheckFinding)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	defer stmt.Close()
+	for _, f := range findings {
+		if _, err := stmt.Exec(report.Run.ID, f.Kind, f.School, f.Year, f.SyncID, f.Course, f.Per, f.Term, f.Teacher, f.PermID, f.StudentName, f.Detail); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return report, tx.Commit()
+}
+
+func scanCheckRun(row interface{ Scan(...interface{}) error }) (*types.CheckRun, error) {
+	run := new(types.CheckRun)
+	if err := row.Scan(&run.ID, &run.At, &run.Rows); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// SelectCheckRuns returns every data quality run, newest first
+func (rs *Roster) SelectCheckRuns() ([]*types.CheckRun, error) {
+	rows, err := rs.query(selectCheckRuns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]*types.CheckRun, 0)
+	for rows.Next() {
+		run, err := scanCheckRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// SelectCheckReport returns the findings of the check run with id, only those of kind if
+// it is set, or sql.ErrNoRows
+func (rs *Roster) SelectCheckReport(runID int64, kind string) (*types.CheckReport, error) {
+	run, err := scanCheckRun(rs.QueryRow(selectCheckRun, runID))
+	if err != nil {
+		return nil, err
+	}
+	return rs.selectFindings(run, kind)
+}
+
+// LatestCheckReport returns the findings of the most recent download, only those of kind
+// if it is set, or sql.ErrNoRows
+func (rs *Roster) LatestCheckReport(kind string) (*types.CheckReport, error) {
+	run, err := scanCheckRun(rs.QueryRow(selectLatestCheckRun))
+	if err != nil {
+		return nil, err
+	}
+	return rs.selectFindings(run, kind)
+}
+
+func (rs *Roster) selectFindings(run *types.CheckRun, kind string) (*types.CheckReport, error) {
+	rows, err := rs.query(selectCheckFindings, run.ID, kind, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &types.CheckReport{Run: *run}
+	for rows.Next() {
+		f := new(types.Finding)
+		if err := rows.Scan(&f.Kind, &f.School, &f.Year, &f.SyncID, &f.Course, &f.Per, &f.Term, &f.Teacher, &f.PermID, &f.StudentName, &f.Detail); err != nil {
+			return nil, err
+		}
+		report.Findings = append(report.Findings, f)
+	}
+	types.SortFindings(report.Findings)
+	return report, rows.Err()
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// selectQueryStu415s filters on every types.Query field; an empty filter argument matches every row
+const selectQueryStu415s = stu415Joins + ` WHERE ` + scopeClause + `
+	AND (CAST(? AS TEXT)='' OR c.term=? OR c.term_name=?)
+	AND (CAST(? AS TEXT)='' OR c.per=?)
+	AND (CAST(? AS TEXT)='' OR c.teacher=?)
+	AND (CAST(? AS TEXT)='' OR c.course_id_and_title=? OR c.course_id_and_title IN (SELECT course_id_and_title FROM courses WHERE course_id=?))
+	AND (CAST(? AS TEXT)='' OR c.section_id=?)
+	AND (CAST(? AS TEXT)='' OR c.room=?)
+	AND (CAST(? AS TEXT)='' OR s.grade=?)
+	AND (CAST(? AS TEXT)='' OR s.gender=?)
+	AND (CAST(? AS TEXT)='' OR s.perm_id=? OR lower(s.student_name) LIKE ? ESCAPE '!')`
+
+// stu415Column maps Stu415 json field names to their stu415Joins columns
+var stu415Column = map[string]string{
+	"organization_name":   "c.organization_name",
+	"school_year":         "c.school_year",
+	"student_name":        "s.student_name",
+	"perm_id":             "s.perm_id",
+	"gender":              "s.gender",
+	"grade":               "s.grade",
+	"term_name":           "c.term_name",
+	"per":                 "c.per",
+	"term":                "c.term",
+	"section_id":          "c.section_id",
+	"course_id_and_title": "c.course_id_and_title",
+	"meet_days":           "c.meet_days",
+	"teacher":             "c.teacher",
+	"teacher_name":        "c.teacher_name",
+	"room":                "c.room",
+	"prescheduled":        "e.prescheduled",
+	"sync_id":             "c.sync_id",
+}
+
+// SelectStu415s returns the roster rows matching q. Fields left out of q.Fields are empty
+func (rs *Roster) SelectStu415s(q types.Query) (types.Stu415s, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	args, err := rs.scopeArgs([]types.Scope{q.Scope})
+	if err != nil {
+		return nil, err
+	}
+	student := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(q.Student))
+	args = append(args,
+		q.Term, q.Term, q.Term,
+		q.Per, q.Per,
+		cleanEmail(q.Teacher), cleanEmail(q.Teacher),
+		q.Course, q.Course, q.Course,
+		q.SectionID, q.SectionID,
+		q.Room, q.Room,
+		q.Grade, q.Grade,
+		q.Gender, q.Gender,
+		q.Student, types.PermIDEmail(q.Student), "%"+student+"%",
+	)
+
+	fields := q.Columns()
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = stu415Column[f]
+	}
+	query := `SELECT ` + strings.Join(cols, ", ") + ` ` + selectQueryStu415s + queryOrder(q.Sort)
+	if q.Limit > 0 || q.Offset > 0 {
+		limit := int64(q.Limit)
+		if limit == 0 {
+			limit = math.MaxInt64
+		}
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, q.Offset)
+	}
+
+	// ad hoc orders and projections are not worth keeping prepared
+	rows, err := rs.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stu415s: %w", err)
+	}
+	defer rows.Close()
+
+	s415s := make(types.Stu415s, 0)
+	for rows.Next() {
+		s := new(types.Stu415)
+		dest := make([]interface{}, len(fields))
+		for i, f := range fields {
+			dest[i] = s.Field(f)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan stu415 row %d: %w", len(s415s)+1, err)
+		}
+		s415s = append(s415s, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read stu415s: %w", err)
+	}
+	return s415s, nil
+}
+
+// queryOrder returns an ORDER BY for sort fields, then period, course and student so pages are stable
+func queryOrder(sort []string) string {
+	var terms []string
+	for _, f := range sort {
+		dir := ""
+		if strings.HasPrefix(f, "-") {
+			f, dir = f[1:], " DESC"
+		}
+		if f == "per" {
+			terms = append(terms, "c.per_num"+dir)
+		}
+		terms = append(terms, stu415Column[f]+dir)
+	}
+	terms = append(terms, "c.per_num", "c.per", "c.course_id_and_title", "s.student_name", "s.perm_id", "c.id")
+	return ` ORDER BY ` + strings.Join(terms, ", ")
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// RosterStore is the roster API. Roster serves SQLite and PostgreSQL through their SQL
+// dialects and Memory keeps rosters in process memory; NewStore chooses one from the DSN
+type RosterStore interface {
+	// replacement
+	UpdateRosters(s415s types.Stu415s, emails [][]string) error
+	DownloadRosters(wait time.Duration, synergyUser, synergyPassword string) error
+	CreateNewStu415AndStaffEmails() error
+
+	// inserts
+	InsertStu415s(s415s types.Stu415s) error
+	InsertStaff(staff []*types.Staff) error
+	InsertSyncIDMappings(maps []*types.SyncIDMapping) error
+	InsertOverride(o *types.Override) error
+	ImportSectionStaff(staff []*types.SectionStaff) error
+	SaveRule(r *types.Rule) error
+
+	// rosters
+	SelectClassesByTeacher(email string, scope ...types.Scope) ([]*types.Class, error)
+	SelectCrossListedByTeacher(email string, scope ...types.Scope) ([]*types.ClassGroup, error)
+	SelectClassByID(sid string, scope ...types.Scope) (*types.Class, error)
+	SelectStu415sBySyncID(sid string, scope ...types.Scope) (types.Stu415s, error)
+	SelectStu415sByTeacherPeriod(email, per string, scope ...types.Scope) (types.Stu415s, error)
+	SelectStu415sByTeacher(email string, scope ...types.Scope) (types.Stu415s, error)
+	SelectStu415sBySection(sectionID string, scope ...types.Scope) (types.Stu415s, error)
+	SelectStu415s(q types.Query) (types.Stu415s, error)
+	SelectScheduleByStudent(permID string, scope ...types.Scope) (*types.Student, error)
+	SelectAllStudents(scope ...types.Scope) ([]*types.Student, error)
+	SelectAllTeachers(scope ...types.Scope) ([]*types.Teacher, error)
+	SelectPartitions() ([]types.Partition, error)
+	CurrentYear(school string) (string, error)
+	ResolveSyncID(sid string) (string, error)
+	Search(q string) (*types.SearchResults, error)
+	SelectAnalytics(caps types.SizeCaps, scope ...types.Scope) (*types.Analytics, error)
+	SelectRoomReport(scope ...types.Scope) (*types.RoomReport, error)
+
+	// history and diffs
+	SelectStu415sByTeacherAsOf(email string, t time.Time, scope ...types.Scope) (types.Stu415s, error)
+	SelectClassesByTeacherAsOf(email string, t time.Time, scope ...types.Scope) ([]*types.Class, error)
+	SelectClassByIDAsOf(sid string, t time.Time, scope ...types.Scope) (*types.Class, error)
+	SelectRosterRuns() ([]*types.RosterRun, error)
+	SelectDiff(runID int64) (*types.RosterDiff, error)
+	LatestDiff() (*types.RosterDiff, error)
+	SelectCheckRuns() ([]*types.CheckRun, error)
+	SelectCheckReport(runID int64, kind string) (*types.CheckReport, error)
+	LatestCheckReport(kind string) (*types.CheckReport, error)
+
+	// staff
+	SelectStaffByEmail(email string) (*types.Staff, error)
+	SelectStaffByName(name string) ([]*types.Staff, error)
+	SelectAllStaff() ([]*types.Staff, error)
+	SelectSectionStaff(syncID string, scope ...types.Scope) ([]*types.SectionStaff, error)
+	DeleteSectionStaff(syncID, email string, scope ...types.Scope) error
+
+	// rules and overrides
+	SelectRules() (types.Rules, error)
+	DeleteRule(name string) error
+	SelectOverrides() (types.Overrides, error)
+	SelectOverride(id int64) (*types.Override, error)
+	DeleteOverride(id int64) error
+	DeleteExpiredOverrides(t time.Time) (int64, error)
+
+	// schema
+	MigrationStatus() ([]*MigrationStatus, error)
+	PendingMigrations() ([]Migration, error)
+	Migrate() ([]Migration, error)
+
+	Close() error
+}
+
+var _ RosterStore = (*Roster)(nil)
+
+// NewStore returns the store for dsn configured with opts: an empty Memory for "memory",
+// or a migrated Roster for a postgres:// URL or SQLite path (see Open)
+func NewStore(dsn string, opts Options) (RosterStore, error) {
+	if dsn == memoryDSN {
+		return NewMemory(opts), nil
+	}
+	rs, err := New(dsn)
+	if err != nil {
+		return nil, err
+	}
+	rs.Options = opts
+	return rs, nil
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// TestRosterStore runs the RosterStore contract against SQLite on disk and in memory, the
+// Memory store, and PostgreSQL when ROSTER_TEST_POSTGRES names an empty scratch database
+func TestRosterStore(t *testing.T) {
+	dsns := map[string]string{
+		"sqlite":        filepath.Join(t.TempDir(), "rosters.db"),
+		"sqlite-memory": ":memory:",
+		"memory":        "memory",
+	}
+	if dsn := os.Getenv("ROSTER_TEST_POSTGRES"); dsn != "" {
+		dsns["postgres"] = dsn
+	}
+	for name, dsn := range dsns {
+		t.Run(name, func(t *testing.T) {
+			clock := time.Date(2021, 8, 2, 12, 0, 0, 0, time.UTC)
+			rs, err := NewStore(dsn, Options{Log: log.New(io.Discard, "", 0), Now: func() time.Time { return clock }})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { rs.Close() })
+			testRosterStore(t, rs, &clock)
+		})
+	}
+}
+
+// testRosterStore checks rs, moving clock forward between updates
+func testRosterStore(t *testing.T, rs RosterStore, clock *time.Time) {
+	first := *clock
+	if pending, err := rs.PendingMigrations(); err != nil || len(pending) != 0 {
+		t.Fatalf("pending migrations %v, %v", pending, err)
+	}
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+
+	ps, err := rs.SelectPartitions()
+	if err != nil || len(ps) != 1 || ps[0] != (types.Partition{School: "HS", Year: "2021"}) {
+		t.Fatalf("partitions %v, %v", ps, err)
+	}
+	if year, err := rs.CurrentYear(""); err != nil || year != "2021" {
+		t.Fatalf("current year %q, %v", year, err)
+	}
+
+	classes, err := rs.SelectClassesByTeacher("J.Smith@aps.edu")
+	if err != nil || len(classes) != 1 {
+		t.Fatalf("classes %v, %v", classes, err)
+	}
+	alg := classes[0]
+	if len(alg.Students) != 2 || alg.Role != types.RolePrimary || alg.Per != "2" {
+		t.Fatalf("class %+v", alg)
+	}
+	if c, err := rs.SelectClassByID(alg.ID); err != nil || len(c.Students) != 2 {
+		t.Fatalf("class by id %+v, %v", c, err)
+	}
+	if rows, err := rs.SelectStu415sBySection("200"); err != nil || len(rows) != 2 {
+		t.Fatalf("section rows %v, %v", rows, err)
+	}
+	if rows, err := rs.SelectStu415sByTeacherPeriod("a.garcia@aps.edu", "1"); err != nil || len(rows) != 1 {
+		t.Fatalf("teacher period rows %v, %v", rows, err)
+	}
+	rows, err := rs.SelectStu415s(types.Query{Course: "110000", Sort: []string{"-student_name"}, Fields: []string{"student_name"}})
+	if err != nil || len(rows) != 2 || rows[0].StudentName != "Lee, Bo" || rows[0].Teacher != "" {
+		t.Fatalf("query rows %v, %v", rows, err)
+	}
+	if st, err := rs.SelectScheduleByStudent("1"); err != nil || len(st.Schedule) != 2 {
+		t.Fatalf("schedule %+v, %v", st, err)
+	}
+	if students, err := rs.SelectAllStudents(); err != nil || len(students) != 2 {
+		t.Fatalf("students %v, %v", students, err)
+	}
+	if teachers, err := rs.SelectAllTeachers(); err != nil || len(teachers) != 2 {
+		t.Fatalf("teachers %v, %v", teachers, err)
+	}
+	if staff, err := rs.SelectStaffByEmail("a.garcia@aps.edu"); err != nil || staff.Email != "a.garcia@aps.edu" {
+		t.Fatalf("staff %+v, %v", staff, err)
+	}
+
+	co := []*types.SectionStaff{{SyncID: alg.ID, Email: "co@aps.edu", Role: types.RoleCoTeacher}}
+	if err := rs.ImportSectionStaff(co); err != nil {
+		t.Fatal(err)
+	}
+	if classes, err := rs.SelectClassesByTeacher("co@aps.edu"); err != nil || len(classes) != 1 || classes[0].Role != types.RoleCoTeacher {
+		t.Fatalf("co-teacher classes %v, %v", classes, err)
+	}
+	if classes, err := rs.SelectClassesByTeacherAsOf("co@aps.edu", time.Now().Add(time.Minute)); err != nil || len(classes) != 1 {
+		t.Fatalf("co-teacher classes as of now %v, %v", classes, err)
+	}
+	if err := rs.DeleteSectionStaff(alg.ID, "co@aps.edu"); err != nil {
+		t.Fatal(err)
+	}
+	if staff, err := rs.SelectSectionStaff(alg.ID); err != nil || len(staff) != 1 {
+		t.Fatalf("section staff %v, %v", staff, err)
+	}
+
+	runs, err := rs.SelectRosterRuns()
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("runs %v, %v", runs, err)
+	}
+	if checks, err := rs.SelectCheckRuns(); err != nil || len(checks) != 1 {
+		t.Fatalf("check runs %v, %v", checks, err)
+	}
+	a, err := rs.SelectAnalytics(types.SizeCaps{Min: 2})
+	if err != nil || len(a.Sections) != 2 || len(a.OutOfBounds()) != 1 {
+		t.Fatalf("analytics %+v, %v", a, err)
+	}
+	if r, err := rs.SelectRoomReport(); err != nil || len(r.Uses) != 8 {
+		t.Fatalf("room report %+v, %v", r, err)
+	}
+
+	// Kim moves from Algebra to Garcia's Bio
+	*clock = first.Add(time.Hour)
+	if err := rs.UpdateRosters(readRoster(t, `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,"Garcia, Ana",101,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",102,N
+HS,2021,"Kim, Al",2,M,09,Year,3,YR,300,120000 Bio,MTWRF,"Garcia, Ana",103,N
+`), testEmails); err != nil {
+		t.Fatal(err)
+	}
+	d, err := rs.LatestDiff()
+	if err != nil || d.Run.Rows != 3 || d.Count(types.SectionAdded) != 1 || d.Count(types.EnrollmentRemoved) != 1 {
+		t.Fatalf("diff %+v, %v", d, err)
+	}
+	if rows, err := rs.SelectStu415sByTeacher("j.smith@aps.edu"); err != nil || len(rows) != 1 {
+		t.Fatalf("rows after the move %v, %v", rows, err)
+	}
+	if rows, err := rs.SelectStu415sByTeacherAsOf("j.smith@aps.edu", first.Add(time.Minute)); err != nil || len(rows) != 2 {
+		t.Fatalf("rows before the move %v, %v", rows, err)
+	}
+	if c, err := rs.SelectClassByIDAsOf(alg.ID, first.Add(time.Minute)); err != nil || len(c.Students) != 2 {
+		t.Fatalf("class before the move %+v, %v", c, err)
+	}
+
+	// a download missing Smith's class shrinks the roster too far and changes nothing
+	*clock = first.Add(2 * time.Hour)
+	err = rs.UpdateRosters(readRoster(t, `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,"Garcia, Ana",101,N
+`), testEmails)
+	var guardErr *types.GuardError
+	if !errors.As(err, &guardErr) {
+		t.Fatalf("shrunk roster got %v, want a guard error", err)
+	}
+	if rows, err := rs.SelectStu415sByTeacher("j.smith@aps.edu"); err != nil || len(rows) != 1 {
+		t.Fatalf("rows after a refused update %v, %v", rows, err)
+	}
+	if runs, err := rs.SelectRosterRuns(); err != nil || len(runs) != 2 || !runs[0].At.Equal(first.Add(time.Hour)) {
+		t.Fatalf("runs after a refused update %v, %v", runs, err)
+	}
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+const (
+	selectRules = `SELECT name, position, course, school, grade, per, teacher_match, action, teacher, role, title FROM roster_rules WHERE enabled=1 ORDER BY position, name`
+	upsertRule  = `INSERT INTO roster_rules(name, position, course, school, grade, per, teacher_match, action, teacher, role, title, enabled) VALUES(?,?,?,?,?,?,?,?,?,?,?,1) ON CONFLICT(name) DO UPDATE SET position=excluded.position, course=excluded.course, school=excluded.school, grade=excluded.grade, per=excluded.per, teacher_match=excluded.teacher_match, action=excluded.action, teacher=excluded.teacher, role=excluded.role, title=excluded.title, enabled=1`
+	deleteRule  = `DELETE FROM roster_rules WHERE name=?`
+)
+
+// SelectRules returns the enabled rules in the roster_rules table in position order
+func (rs *Roster) SelectRules() (types.Rules, error) {
+	rows, err := rs.query(selectRules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make(types.Rules, 0)
+	for rows.Next() {
+		r := new(types.Rule)
+		if err := rows.Scan(&r.Name, &r.Position, &r.Match.Course, &r.Match.School, &r.Match.Grade, &r.Match.Per, &r.Match.Teacher, &r.Action, &r.Teacher, &r.Role, &r.Title); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// SaveRule adds or replaces a rule in the roster_rules table
+func (rs *Roster) SaveRule(r *types.Rule) error {
+	if err := (types.Rules{r}).Validate(); err != nil {
+		return err
+	}
+	_, err := rs.Exec(upsertRule, r.Name, r.Position, r.Match.Course, r.Match.School, r.Match.Grade, r.Match.Per, r.Match.Teacher, r.Action, r.Teacher, r.Role, r.Title)
+	return err
+}
+
+// DeleteRule removes the named rule from the roster_rules table
+func (rs *Roster) DeleteRule(name string) error {
+	_, err := rs.Exec(deleteRule, name)
+	return err
+}
+
+// rules returns rs.Rules if set, or the rules in the roster_rules table
+func (rs *Roster) rules() (types.Rules, error) {
+	if rs.Rules != nil {
+		return rs.Rules, nil
+	}
+	return rs.SelectRules()
+}
@@ -0,0 +1,42 @@
+package store
+
+import "github.com/matthewkappus/rosterUpdate/src/types"
+
+// The roster schema is created by migrations/0001_roster_schema.sql.
+// Queries select stu415 rows from the joined tables so they can filter on indexed columns.
+const (
+	// stu415Columns are the flat report columns in Stu415 field order
+	stu415Columns = `c.organization_name, c.school_year, s.student_name, s.perm_id, s.gender, s.grade, c.term_name, c.per, c.term, c.section_id, c.course_id_and_title, c.meet_days, c.teacher, c.teacher_name, c.room, e.prescheduled, c.sync_id`
+	stu415Joins   = `FROM enrollments e JOIN sections c ON c.id=e.section JOIN students s ON s.perm_id=e.perm_id`
+
+	// scopeClause filters sections c by school and year; Roster.scopeArgs supplies its arguments
+	scopeClause = `(CAST(? AS TEXT)='' OR c.organization_name=?) AND (CAST(? AS TEXT)='` + types.AnyYear + `' OR c.school_year=?)`
+
+	// inStagedPartition matches sections c in the schools and years an update replaces
+	inStagedPartition = `(c.organization_name, c.school_year) IN (SELECT organization_name, school_year FROM stage_partitions)`
+
+	// inLatestPartition matches sections c in the latest stored year of each school an update
+	// adds a partition for
+	inLatestPartition = `(c.organization_name, c.school_year) IN (SELECT organization_name, max(school_year) FROM sections
+		WHERE organization_name IN (SELECT p.organization_name FROM stage_partitions p
+			WHERE NOT EXISTS (SELECT 1 FROM sections x WHERE x.organization_name=p.organization_name AND x.school_year=p.school_year))
+		GROUP BY organization_name)`
+)
+
+// clearPartitions empties the staged partitions, children first. Students and courses
+// are removed when nothing refers to them; students the update reloads are updated by mergeStage
+var clearPartitions = []string{
+	`DELETE FROM enrollments WHERE section IN (SELECT c.id FROM sections c WHERE ` + inStagedPartition + `)`,
+	`DELETE FROM sections WHERE id IN (SELECT c.id FROM sections c WHERE ` + inStagedPartition + `)`,
+	`DELETE FROM students WHERE perm_id NOT IN (SELECT perm_id FROM enrollments)`,
+	`DELETE FROM courses WHERE course_id_and_title NOT IN (SELECT course_id_and_title FROM sections)`,
+}
+
+// clearRosters empties roster and staff tables in every partition, children first
+var clearRosters = []string{
+	`DELETE FROM enrollments`,
+	`DELETE FROM sections`,
+	`DELETE FROM students`,
+	`DELETE FROM courses`,
+	`DELETE FROM staff`,
+}
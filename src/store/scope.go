@@ -0,0 +1,77 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+const (
+	selectCurrentYear     = `SELECT coalesce(max(school_year), '') FROM sections WHERE CAST(? AS TEXT)='' OR organization_name=?`
+	selectCurrentYearAsOf = `SELECT coalesce(max(school_year), '') FROM enrollment_history WHERE valid_from<=? AND (valid_to IS NULL OR valid_to>?) AND (CAST(? AS TEXT)='' OR organization_name=?)`
+	selectPartitions      = `SELECT DISTINCT organization_name, school_year FROM sections ORDER BY school_year DESC, organization_name`
+	historyScopeClause    = `(CAST(? AS TEXT)='' OR organization_name=?) AND (CAST(? AS TEXT)='` + types.AnyYear + `' OR school_year=?)`
+)
+
+// CurrentYear returns the latest school year on the roster for school, or for every school if empty
+func (rs *Roster) CurrentYear(school string) (string, error) {
+	var year string
+	err := rs.QueryRow(selectCurrentYear, school, school).Scan(&year)
+	return year, err
+}
+
+// SelectPartitions returns the schools and years on the roster, newest year first
+func (rs *Roster) SelectPartitions() ([]types.Partition, error) {
+	rows, err := rs.query(selectPartitions)
+	if err != nil {
+		return nil, err
+	}
+	return scanPartitions(rows)
+}
+
+// scanPartitions reads and closes rows of schools and years
+func scanPartitions(rows *sql.Rows) ([]types.Partition, error) {
+	defer rows.Close()
+
+	ps := make([]types.Partition, 0)
+	for rows.Next() {
+		var p types.Partition
+		if err := rows.Scan(&p.School, &p.Year); err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+	return ps, rows.Err()
+}
+
+// scopeArgs returns the scopeClause arguments for an optional scope.
+// Without a year the current year of the scoped school is used
+func (rs *Roster) scopeArgs(scope []types.Scope) ([]interface{}, error) {
+	var sc types.Scope
+	if len(scope) > 0 {
+		sc = scope[0]
+	}
+	if sc.Year == "" {
+		var err error
+		if sc.Year, err = rs.CurrentYear(sc.School); err != nil {
+			return nil, err
+		}
+	}
+	return []interface{}{sc.School, sc.School, sc.Year, sc.Year}, nil
+}
+
+// historyScopeArgs returns the historyScopeClause arguments for an optional scope at time t.
+// Without a year the latest school year on the roster at t is used
+func (rs *Roster) historyScopeArgs(t time.Time, scope []types.Scope) ([]interface{}, error) {
+	var sc types.Scope
+	if len(scope) > 0 {
+		sc = scope[0]
+	}
+	if sc.Year == "" {
+		if err := rs.QueryRow(selectCurrentYearAsOf, t, t, sc.School, sc.School).Scan(&sc.Year); err != nil {
+			return nil, err
+		}
+	}
+	return []interface{}{sc.School, sc.School, sc.Year, sc.Year}, nil
+}
@@ -0,0 +1,72 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package store
+
+import (
+	"database/sql"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// The search index is created on first use rather than by a migration so databases stay
+// readable by builds without FTS5
+const (
+	createSearchIndex = `CREATE VIRTUAL TABLE IF NOT EXISTS roster_search USING fts5(kind UNINDEXED, ref UNINDEXED, name, ident, course, tokenize='unicode61 remove_diacritics 2')`
+	countSearchIndex  = `SELECT count(*) FROM roster_search`
+	selectSearchHits  = `SELECT kind, ref, rank FROM roster_search WHERE roster_search MATCH ? ORDER BY rank LIMIT ?`
+)
+
+var rebuildSearchIndex = []string{
+	createSearchIndex,
+	`DELETE FROM roster_search`,
+	`INSERT INTO roster_search(kind, ref, name, ident, course) SELECT kind, ref, name, ident, course FROM (` + searchDocuments + `)`,
+}
+
+// rebuildSearch reloads the SQLite search index from the roster in tx
+func (rs *Roster) rebuildSearch(tx *sql.Tx) error {
+	if rs.backend() != sqliteDialect {
+		return nil
+	}
+	return execAll(tx, rebuildSearchIndex)
+}
+
+// selectFTSHits ranks index matches for terms, building the index if it is missing or empty
+func (rs *Roster) selectFTSHits(terms []string) ([]types.SearchHit, error) {
+	if _, err := rs.Exec(createSearchIndex); err != nil {
+		return nil, err
+	}
+	var n int
+	if err := rs.QueryRow(countSearchIndex).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		tx, err := rs.Begin()
+		if err != nil {
+			return nil, err
+		}
+		if err := rs.rebuildSearch(tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := rs.query(selectSearchHits, ftsMatch(terms), maxSearchHits)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []types.SearchHit
+	for rows.Next() {
+		var h types.SearchHit
+		if err := rows.Scan(&h.Kind, &h.Ref, &h.Rank); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
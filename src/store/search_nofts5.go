@@ -0,0 +1,22 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// ErrNoFTS5 is returned by SQLite searches in builds without the sqlite_fts5 tag
+var ErrNoFTS5 = errors.New("roster search needs SQLite FTS5; build with -tags sqlite_fts5 (see README)")
+
+func (rs *Roster) rebuildSearch(tx *sql.Tx) error {
+	return nil
+}
+
+func (rs *Roster) selectFTSHits(terms []string) ([]types.SearchHit, error) {
+	return nil, ErrNoFTS5
+}
@@ -0,0 +1,19 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchWithoutFTS5(t *testing.T) {
+	rs := newTestRoster(t)
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Search("lee"); !errors.Is(err, ErrNoFTS5) {
+		t.Fatalf("Search() = %v, want ErrNoFTS5", err)
+	}
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// maxSearchHits is the most index matches a search loads
+const maxSearchHits = 50
+
+// searchDocuments are the rows of the search index: students by name and perm id, the staff
+// directory by name and email, and sections by teacher, section id and course title
+const searchDocuments = `SELECT '` + types.SearchStudent + `' AS kind, perm_id AS ref, student_name AS name, replace(perm_id, '` + types.PermIDDomain + `', '') AS ident, '' AS course FROM students
+	UNION ALL SELECT '` + types.SearchStaff + `', email, formatted_name, email, '' FROM staff
+	UNION ALL SELECT DISTINCT '` + types.SearchSection + `', sync_id, teacher_name, teacher || ' ' || section_id, course_id_and_title FROM sections`
+
+// Search finds students, staff and sections whose names, perm ids, emails or course titles
+// have words starting with every term in q. Students come with their current schedule.
+// SQLite ranks matches with an FTS5 index rebuilt on every update and needs a build with the
+// sqlite_fts5 tag; without it Search fails. PostgreSQL scans the roster with LIKE
+func (rs *Roster) Search(q string) (*types.SearchResults, error) {
+	terms := types.SearchTerms(q)
+	if len(terms) == 0 {
+		return new(types.SearchResults), nil
+	}
+
+	var hits []types.SearchHit
+	var err error
+	if rs.backend() == sqliteDialect {
+		hits, err = rs.selectFTSHits(terms)
+	} else {
+		hits, err = rs.selectLikeHits(terms)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return loadSearchHits(rs, hits)
+}
+
+// loadSearchHits loads the students, staff and sections hits refer to, skipping repeats
+func loadSearchHits(rs RosterStore, hits []types.SearchHit) (*types.SearchResults, error) {
+	res := new(types.SearchResults)
+	seen := make(map[types.SearchHit]bool)
+	for _, h := range hits {
+		k := types.SearchHit{Kind: h.Kind, Ref: h.Ref}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		var err error
+		switch h.Kind {
+		case types.SearchStudent:
+			var st *types.Student
+			if st, err = rs.SelectScheduleByStudent(h.Ref); err == nil {
+				res.Students = append(res.Students, st)
+			}
+		case types.SearchStaff:
+			var st *types.Staff
+			if st, err = rs.SelectStaffByEmail(h.Ref); err == nil {
+				res.Staff = append(res.Staff, st)
+			}
+		case types.SearchSection:
+			var c *types.Class
+			if c, err = rs.SelectClassByID(h.Ref); err == nil {
+				res.Sections = append(res.Sections, c)
+			}
+		}
+		// matches outside the current school year have no rows to load
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("search: load %s %s: %w", h.Kind, h.Ref, err)
+		}
+	}
+	return res, nil
+}
+
+// selectLikeHits matches documents containing every term, ranking those where terms start
+// a word first
+func (rs *Roster) selectLikeHits(terms []string) ([]types.SearchHit, error) {
+	where := make([]string, len(terms))
+	args := make([]interface{}, len(terms))
+	escape := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	for i, t := range terms {
+		where[i] = `lower(d.name || ' ' || d.ident || ' ' || d.course) LIKE ? ESCAPE '!'`
+		args[i] = "%" + escape.Replace(t) + "%"
+	}
+	rows, err := rs.query(`SELECT d.kind, d.ref, d.name, d.ident, d.course FROM (`+searchDocuments+`) d WHERE `+strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []types.SearchHit
+	for rows.Next() {
+		var h types.SearchHit
+		var name, ident, course string
+		if err := rows.Scan(&h.Kind, &h.Ref, &name, &ident, &course); err != nil {
+			return nil, err
+		}
+		h.Rank = wordStartRank(terms, name+" "+ident+" "+course)
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return topHits(hits), nil
+}
+
+// wordStartRank is minus the number of terms that start a word of text
+func wordStartRank(terms []string, text string) float64 {
+	var rank float64
+	words := types.SearchTerms(text)
+	for _, t := range terms {
+		for _, w := range words {
+			if strings.HasPrefix(w, t) {
+				rank--
+				break
+			}
+		}
+	}
+	return rank
+}
+
+// topHits returns the best maxSearchHits hits, best first
+func topHits(hits []types.SearchHit) []types.SearchHit {
+	sortHits(hits)
+	if len(hits) > maxSearchHits {
+		hits = hits[:maxSearchHits]
+	}
+	return hits
+}
+
+// sortHits orders hits best first, keeping index order for ties
+func sortHits(hits []types.SearchHit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Rank < hits[j].Rank
+	})
+}
+
+// ftsMatch returns an FTS5 query matching words that start with every term
+func ftsMatch(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+const (
+	sectionStaffColumns = `organization_name, school_year, sync_id, email, role, source`
+
+	clearRebuiltSectionStaff = `DELETE FROM section_staff WHERE source<>'` + types.StaffFromImport + `' AND ((organization_name, school_year) IN (SELECT organization_name, school_year FROM stage_partitions)
+		OR NOT EXISTS (SELECT 1 FROM sections c WHERE c.organization_name=section_staff.organization_name AND c.school_year=section_staff.school_year AND c.sync_id=section_staff.sync_id))`
+	insertPrimarySectionStaff = `INSERT INTO section_staff(` + sectionStaffColumns + `) SELECT DISTINCT organization_name, school_year, sync_id, teacher, '` + types.RolePrimary + `', '` + types.StaffFromSynergy + `' FROM sections WHERE teacher<>'' ON CONFLICT DO NOTHING`
+	insertSectionStaff        = `INSERT INTO section_staff(` + sectionStaffColumns + `) VALUES(?,?,?,?,?,?) ON CONFLICT DO NOTHING`
+	importSectionStaff        = `INSERT INTO section_staff(` + sectionStaffColumns + `) SELECT DISTINCT c.organization_name, c.school_year, c.sync_id, ?, ?, ? FROM sections c WHERE c.sync_id=? AND ` + scopeClause + `
+		ON CONFLICT(organization_name, school_year, sync_id, email) DO UPDATE SET role=excluded.role, source=excluded.source`
+	deleteSectionStaff = `DELETE FROM section_staff WHERE sync_id=? AND email=? AND ` + historyScopeClause
+	selectSectionStaff = `SELECT ` + sectionStaffColumns + ` FROM section_staff WHERE sync_id=? AND ` + historyScopeClause + ` ORDER BY organization_name, school_year, role='` + types.RolePrimary + `' DESC, role, email`
+	selectStaffRoles   = `SELECT organization_name, school_year, sync_id, role FROM section_staff WHERE email=?`
+
+	// A primary teacher sees the sections listing them; other roles see every section with the sync id
+	selectStu415sByStaff = selectStu415s + ` WHERE EXISTS (SELECT 1 FROM section_staff ss WHERE ss.organization_name=c.organization_name AND ss.school_year=c.school_year AND ss.sync_id=c.sync_id
+		AND ss.email=? AND (ss.role<>'` + types.RolePrimary + `' OR ss.email=c.teacher)) AND ` + scopeClause + orderStu415s
+)
+
+// replaceSectionStaff rebuilds Synergy and rule staff for the merged sections, keeping imports
+// and staff of other partitions
+func replaceSectionStaff(tx *sql.Tx, ruleStaff []*types.SectionStaff) error {
+	if err := execAll(tx, []string{clearRebuiltSectionStaff, insertPrimarySectionStaff}); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(insertSectionStaff)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, st := range ruleStaff {
+		if _, err := stmt.Exec(st.School, st.Year, st.SyncID, cleanEmail(st.Email), st.Role, st.Source); err != nil {
+			return fmt.Errorf("section staff %s in %s: %v", st.Email, st.SyncID, err)
+		}
+	}
+	return nil
+}
+
+// ImportSectionStaff attaches staff to sections, replacing their role if already attached.
+// Staff without a School or Year are attached in the current year of each school with the
+// section. Imported staff are kept across updates until deleted
+func (rs *Roster) ImportSectionStaff(staff []*types.SectionStaff) error {
+	args := make([][]interface{}, len(staff))
+	for i, st := range staff {
+		if !types.ValidRole(st.Role) {
+			return fmt.Errorf("section staff %s in %s: unknown role %q", st.Email, st.SyncID, st.Role)
+		}
+		st.Source = types.StaffFromImport
+		scope, err := rs.scopeArgs([]types.Scope{{School: st.School, Year: st.Year}})
+		if err != nil {
+			return err
+		}
+		args[i] = append([]interface{}{cleanEmail(st.Email), st.Role, st.Source, st.SyncID}, scope...)
+	}
+
+	tx, err := rs.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(importSectionStaff)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for i, st := range staff {
+		res, err := stmt.Exec(args[i]...)
+		if err == nil {
+			if n, _ := res.RowsAffected(); n == 0 {
+				err = errors.New("no such section on the roster")
+			}
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("section staff %s in %s: %v", st.Email, st.SyncID, err)
+		}
+	}
+	if err := recordStaffHistory(tx, time.Now().UTC()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteSectionStaff detaches email from the section with syncID. Query methods take an
+// optional scope; see types.Scope
+func (rs *Roster) DeleteSectionStaff(syncID, email string, scope ...types.Scope) error {
+	args, err := rs.scopeArgs(scope)
+	if err != nil {
+		return err
+	}
+	tx, err := rs.Begin()
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(deleteSectionStaff, append([]interface{}{syncID, cleanEmail(email)}, args...)...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+	if err := recordStaffHistory(tx, time.Now().UTC()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// SelectSectionStaff returns the staff attached to the section with syncID, primary teachers first
+func (rs *Roster) SelectSectionStaff(syncID string, scope ...types.Scope) ([]*types.SectionStaff, error) {
+	args, err := rs.scopeArgs(scope)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := rs.query(selectSectionStaff, append([]interface{}{syncID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	staff := make([]*types.SectionStaff, 0)
+	for rows.Next() {
+		st := new(types.SectionStaff)
+		if err := rows.Scan(&st.School, &st.Year, &st.SyncID, &st.Email, &st.Role, &st.Source); err != nil {
+			return nil, err
+		}
+		staff = append(staff, st)
+	}
+	return staff, rows.Err()
+}
+
+// selectStaffRoles maps the school, year and sync id of the sections email is attached to onto
+// their role
+func (rs *Roster) selectStaffRoles(email string) (map[string]string, error) {
+	rows, err := rs.query(selectStaffRoles, email)
+	if err != nil {
+		return nil, err
+	}
+	return scanStaffRoles(rows)
+}
+
+// scanStaffRoles reads and closes rows of school, year, sync id and role
+func scanStaffRoles(rows *sql.Rows) (map[string]string, error) {
+	defer rows.Close()
+
+	roles := make(map[string]string)
+	for rows.Next() {
+		var school, year, sid, role string
+		if err := rows.Scan(&school, &year, &sid, &role); err != nil {
+			return nil, err
+		}
+		roles[staffRoleKey(school, year, sid)] = role
+	}
+	return roles, rows.Err()
+}
+
+func staffRoleKey(school, year, syncID string) string {
+	return school + "|" + year + "|" + syncID
+}
+
+// setRoles sets the role of each class from roles made by scanStaffRoles
+func setRoles(classes []*types.Class, roles map[string]string) {
+	for _, c := range classes {
+		if len(c.Students) > 0 {
+			c.Role = roles[staffRoleKey(c.Students[0].OrganizationName, c.Students[0].SchoolYear, c.ID)]
+		}
+	}
+}
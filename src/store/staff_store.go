@@ -0,0 +1,104 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+const (
+	insertStaff              = `INSERT INTO staff(email, formatted_name, extra) VALUES(?,?,?) ON CONFLICT(email) DO UPDATE SET formatted_name=excluded.formatted_name, extra=excluded.extra`
+	selectStaffByEmail       = `SELECT email, formatted_name, extra FROM staff WHERE email=?`
+	selectStaffByName        = `SELECT email, formatted_name, extra FROM staff WHERE lower(formatted_name)=lower(?)`
+	selectStaffByTeacherName = `SELECT DISTINCT s.email, s.formatted_name, s.extra FROM staff s JOIN sections c ON c.teacher=s.email WHERE c.teacher_name=?`
+	selectAllStaff           = `SELECT email, formatted_name, extra FROM staff ORDER BY formatted_name`
+)
+
+// InsertStaff replaces the staff rows for the provided emails
+func (rs *Roster) InsertStaff(staff []*types.Staff) error {
+	tx, err := rs.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(insertStaff)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, st := range staff {
+		extra, err := json.Marshal(st.Extra)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(st.Email, st.FormattedName, string(extra)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("insert staff %s: %v", st.Email, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func scanStaff(rows *sql.Rows) (*types.Staff, error) {
+	st := new(types.Staff)
+	var extra sql.NullString
+	if err := rows.Scan(&st.Email, &st.FormattedName, &extra); err != nil {
+		return nil, err
+	}
+	if extra.Valid && extra.String != "" {
+		if err := json.Unmarshal([]byte(extra.String), &st.Extra); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func (rs *Roster) selectStaff(query string, args ...interface{}) ([]*types.Staff, error) {
+	rows, err := rs.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	staff := make([]*types.Staff, 0)
+	for rows.Next() {
+		st, err := scanStaff(rows)
+		if err != nil {
+			return nil, err
+		}
+		staff = append(staff, st)
+	}
+	return staff, rows.Err()
+}
+
+// SelectStaffByEmail returns the staff member with the provided email or sql.ErrNoRows
+func (rs *Roster) SelectStaffByEmail(email string) (*types.Staff, error) {
+	staff, err := rs.selectStaff(selectStaffByEmail, cleanEmail(email))
+	if err != nil {
+		return nil, err
+	}
+	if len(staff) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return staff[0], nil
+}
+
+// SelectStaffByName returns staff whose formatted name matches, ignoring case.
+// If none match, staff listed under that teacher name on a roster are returned
+func (rs *Roster) SelectStaffByName(name string) ([]*types.Staff, error) {
+	staff, err := rs.selectStaff(selectStaffByName, name)
+	if err != nil || len(staff) > 0 {
+		return staff, err
+	}
+	return rs.selectStaff(selectStaffByTeacherName, name)
+}
+
+// SelectAllStaff returns the staff directory ordered by name
+func (rs *Roster) SelectAllStaff() ([]*types.Staff, error) {
+	return rs.selectStaff(selectAllStaff)
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// stageColumns are the flat stu415 columns plus values parsed from them for the normalized tables
+const stageColumns = `organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, teacher_name, room, prescheduled, sync_id, course_id, course_title, per_num`
+
+const (
+	insertStageStu415 = `INSERT INTO stage_stu415(` + stageColumns + `) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+	insertStageStaff  = `INSERT INTO stage_staff(email, formatted_name, extra) VALUES(?,?,?) ON CONFLICT(email) DO UPDATE SET formatted_name=excluded.formatted_name, extra=excluded.extra`
+
+	countStage            = `SELECT count(*) FROM stage_stu415`
+	countStageMissingKeys = `SELECT count(*) FROM stage_stu415 WHERE perm_id='' OR sync_id='' OR course_id_and_title=''`
+	countStageEnrollments = `SELECT count(*) FROM (SELECT DISTINCT organization_name, school_year, sync_id, teacher, perm_id FROM stage_stu415) g`
+	countEnrollments      = `SELECT count(*) FROM enrollments e JOIN sections c ON c.id=e.section WHERE ` + inStagedPartition
+
+	insertStagePartitions = `INSERT INTO stage_partitions(organization_name, school_year) SELECT DISTINCT organization_name, school_year FROM stage_stu415`
+)
+
+var clearStage = []string{
+	`DELETE FROM stage_stu415`,
+	`DELETE FROM stage_staff`,
+	`DELETE FROM stage_partitions`,
+}
+
+// mergeStage copies staged rows into the live tables, parents first. Staff are upserted
+// because the directory is shared by every partition. SQLite needs the WHERE before
+// ON CONFLICT to parse an INSERT ... SELECT
+var mergeStage = []string{
+	`INSERT INTO staff(email, formatted_name, extra) SELECT email, formatted_name, extra FROM stage_staff WHERE true ON CONFLICT(email) DO UPDATE SET formatted_name=excluded.formatted_name, extra=excluded.extra`,
+	`INSERT INTO courses(course_id_and_title, course_id, title) SELECT DISTINCT course_id_and_title, course_id, course_title FROM stage_stu415 WHERE true ON CONFLICT DO NOTHING`,
+	`INSERT INTO students(perm_id, student_name, gender, grade) SELECT perm_id, max(student_name), max(gender), max(grade) FROM stage_stu415 GROUP BY perm_id ON CONFLICT(perm_id) DO UPDATE SET student_name=excluded.student_name, gender=excluded.gender, grade=excluded.grade`,
+	`INSERT INTO sections(sync_id, teacher, teacher_name, section_id, organization_name, school_year, course_id_and_title, per, per_num, term, term_name, meet_days, room) SELECT sync_id, teacher, teacher_name, section_id, organization_name, school_year, course_id_and_title, per, per_num, term, term_name, meet_days, room FROM stage_stu415 WHERE true ON CONFLICT DO NOTHING`,
+	`INSERT INTO enrollments(section, perm_id, prescheduled) SELECT c.id, g.perm_id, g.prescheduled FROM stage_stu415 g JOIN sections c ON c.organization_name=g.organization_name AND c.school_year=g.school_year AND c.sync_id=g.sync_id AND c.teacher=g.teacher WHERE true ON CONFLICT DO NOTHING`,
+}
+
+func execAll(tx *sql.Tx, queries []string) error {
+	for _, q := range queries {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stageStu415s loads s415s into stage_stu415. A rejected row is returned as a *types.RowError
+func stageStu415s(tx *sql.Tx, s415s types.Stu415s) error {
+	stmt, err := tx.Prepare(insertStageStu415)
+	if err != nil {
+		return fmt.Errorf("prepare staging: %v", err)
+	}
+	defer stmt.Close()
+
+	for i, s := range s415s {
+		if _, err := stmt.Exec(
+			s.OrganizationName,
+			s.SchoolYear,
+			s.StudentName,
+			s.PermID,
+			s.Gender,
+			s.Grade,
+			s.TermName,
+			s.Per,
+			s.Term,
+			s.SectionID,
+			s.CourseIDAndTitle,
+			s.MeetDays,
+			s.Teacher,
+			s.TeacherName,
+			s.Room,
+			s.Prescheduled,
+			s.SyncID,
+			s.CourseID(),
+			s.CourseTitle(),
+			s.Period().Num,
+		); err != nil {
+			return &types.RowError{Row: i, PermID: s.PermID, SyncID: s.SyncID, Err: err}
+		}
+	}
+	return nil
+}
+
+// stageStaff loads staff into stage_staff
+func stageStaff(tx *sql.Tx, staff []*types.Staff) error {
+	stmt, err := tx.Prepare(insertStageStaff)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, st := range staff {
+		extra, err := json.Marshal(st.Extra)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(st.Email, st.FormattedName, string(extra)); err != nil {
+			return fmt.Errorf("stage staff %s: %v", st.Email, err)
+		}
+	}
+	return nil
+}
+
+// validateStage checks that every row was staged and has the keys the live tables need
+func validateStage(tx *sql.Tx, want int) error {
+	var staged, missing int
+	if err := tx.QueryRow(countStage).Scan(&staged); err != nil {
+		return err
+	}
+	if staged == 0 || staged < want {
+		return fmt.Errorf("staged %d of %d rows", staged, want)
+	}
+	if err := tx.QueryRow(countStageMissingKeys).Scan(&missing); err != nil {
+		return err
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d staged rows are missing a perm id, sync id or course", missing)
+	}
+	return nil
+}
+
+// checkMerge confirms every staged enrollment reached the live tables
+func checkMerge(tx *sql.Tx) error {
+	var staged, live int
+	if err := tx.QueryRow(countStageEnrollments).Scan(&staged); err != nil {
+		return err
+	}
+	if err := tx.QueryRow(countEnrollments).Scan(&live); err != nil {
+		return err
+	}
+	if live != staged {
+		return fmt.Errorf("merged %d of %d staged enrollments", live, staged)
+	}
+	return nil
+}
+
+// replaceRosters stages, validates and swaps in the schools and years in s415s along with the
+// staff directory and section staff, returning their changes from the previous roster.
+// Other partitions are left alone. Nothing is visible to readers until tx commits
+func (rs *Roster) replaceRosters(tx *sql.Tx, s415s types.Stu415s, staff []*types.Staff, sectionStaff []*types.SectionStaff, maps []*types.SyncIDMapping) (*types.RosterDiff, error) {
+	if err := execAll(tx, clearStage); err != nil {
+		return nil, err
+	}
+	if err := stageStaff(tx, staff); err != nil {
+		return nil, err
+	}
+	if err := stageStu415s(tx, s415s); err != nil {
+		return nil, err
+	}
+	if err := validateStage(tx, len(s415s)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(insertStagePartitions); err != nil {
+		return nil, err
+	}
+
+	live, err := queryStu415s(tx, selectPartitionStu415s)
+	if err != nil {
+		return nil, err
+	}
+	staged, err := queryStu415s(tx, selectStageStu415s)
+	if err != nil {
+		return nil, err
+	}
+	old, err := queryStu415s(tx, selectGuardStu415s)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(selectPartitions)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := scanPartitions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := rs.checkGuard(old, staged, stored); err != nil {
+		return nil, err
+	}
+
+	diff, err := recordDiff(tx, live, staged, rs.now())
+	if err != nil {
+		return nil, fmt.Errorf("diff: %v", err)
+	}
+
+	if err := execAll(tx, clearPartitions); err != nil {
+		return nil, err
+	}
+	if err := execAll(tx, mergeStage); err != nil {
+		return nil, err
+	}
+	if err := checkMerge(tx); err != nil {
+		return nil, err
+	}
+	if err := replaceSectionStaff(tx, sectionStaff); err != nil {
+		return nil, err
+	}
+	if err := rs.rebuildSearch(tx); err != nil {
+		return nil, fmt.Errorf("search index: %v", err)
+	}
+	if err := recordHistory(tx, diff.Run); err != nil {
+		return nil, fmt.Errorf("history: %v", err)
+	}
+	return diff, insertSyncIDMappings(tx, maps)
+}
+
+// checkGuard runs the configured Guard, or DefaultGuard, over the old and staged rows and the
+// stored partitions. Violations are logged instead of returned when o.Force is set
+func (o *Options) checkGuard(old, staged types.Stu415s, stored []types.Partition) error {
+	g := types.DefaultGuard
+	if o.Guard != nil {
+		g = *o.Guard
+	}
+	report := g.Check(old, staged, stored)
+	if report.OK() {
+		return nil
+	}
+	if o.Force {
+		o.logf("forced past guard\n%s", report)
+		return nil
+	}
+	o.logf("%s", report)
+	return &types.GuardError{Report: report}
+}
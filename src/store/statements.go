@@ -0,0 +1,51 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// prepare returns query prepared on rs.DB, preparing it on first use. Statements are
+// shared by every call and closed by Close
+func (rs *Roster) prepare(query string) (*sql.Stmt, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if stmt, ok := rs.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := rs.DB.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	if rs.stmts == nil {
+		rs.stmts = make(map[string]*sql.Stmt)
+	}
+	rs.stmts[query] = stmt
+	return stmt, nil
+}
+
+// query runs a prepared query; callers must close the rows
+func (rs *Roster) query(query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := rs.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(args...)
+}
+
+// Close closes the prepared statements, then the database
+func (rs *Roster) Close() error {
+	rs.mu.Lock()
+	var firstErr error
+	for q, stmt := range rs.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("close statement: %w", err)
+		}
+		delete(rs.stmts, q)
+	}
+	rs.mu.Unlock()
+	if err := rs.DB.Close(); err != nil {
+		return err
+	}
+	return firstErr
+}
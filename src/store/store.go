@@ -2,40 +2,131 @@ package store
 
 import (
 	"database/sql"
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
-	"runtime"
+	"strings"
+	"sync"
+	"time"
 
-	// ok
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/matthewkappus/rosterUpdate/src/types"
 )
 
+// Options configure roster updates. Every RosterStore embeds them
+type Options struct {
+	// TeacherOverrides pins teacher display names to emails during updates
+	TeacherOverrides map[string]string
+	// SyncIDScheme selects the optional fields hashed into class sync ids
+	SyncIDScheme types.SyncIDScheme
+	// Guard checks new rosters before they replace the old one; DefaultGuard is used if nil
+	Guard *types.Guard
+	// Force replaces the roster even if Guard checks fail
+	Force bool
+	// MaxBadRows is how many rows missing a key field an update skips before it fails
+	MaxBadRows int
+	// Rules are applied to every update; the store's saved rules are used if nil
+	Rules types.Rules
+	// Log receives update reports; the standard logger is used if nil
+	Log *log.Logger
+	// Now stamps roster runs; time.Now is used if nil
+	Now func() time.Time
+}
+
 // Roster provides sql access to Class db
 type Roster struct {
 	*sql.DB
+	// DSN is the database the roster was opened from
+	DSN     string
+	dialect *dialect
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+
+	Options
 }
 
-// New returns rosters form provided db
-func New(dbName string) (*Roster, error) {
-	dir := filepath.Join(UserHomeDir(), "data")
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		os.MkdirAll(dir, 0666)
-	}
-	db, err := sql.Open("sqlite3", filepath.Join(dir, dbName))
+// New returns rosters from the sqlite database at dsn after applying pending migrations
+func New(dsn string) (*Roster, error) {
+	rs, err := Open(dsn)
 	if err != nil {
 		return nil, err
 	}
-	return &Roster{db}, nil
+	if _, err := rs.Migrate(); err != nil {
+		rs.Close()
+		return nil, err
+	}
+	return rs, nil
 }
 
-// UserHomeDir returns the windows/unix homedir: Store rosters.db in home/data/rosters.db
-func UserHomeDir() string {
-	if runtime.GOOS == "windows" {
-		home := os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
-		if home == "" {
-			home = os.Getenv("USERPROFILE")
+// Open returns rosters from the database at dsn without migrating it. dsn is a
+// postgres:// URL, or a SQLite path or file: URI with :memory: for a private in-memory
+// database; a missing SQLite directory is created. SQLite enforces foreign keys
+// unless the dsn sets _foreign_keys or _fk. "memory" names the Memory store, which
+// has no database; open it with NewStore
+func Open(dsn string) (*Roster, error) {
+	if dsn == memoryDSN {
+		return nil, fmt.Errorf("open %s: the in-memory store has no database; use NewStore", dsn)
+	}
+	d, name := parseDSN(dsn)
+	if d == sqliteDialect {
+		name = withForeignKeys(name)
+	}
+	if dir := dsnDir(name); d == sqliteDialect && dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
 		}
-		return home
 	}
-	return os.Getenv("HOME")
+	db, err := sql.Open(d.driver, name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(name, ":memory:") || strings.Contains(name, "mode=memory") {
+		// every connection to an in-memory database gets its own empty copy
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open %s: %v", dsn, err)
+	}
+	return &Roster{DB: db, DSN: dsn, dialect: d}, nil
+}
+
+// withForeignKeys adds _foreign_keys=on to a go-sqlite3 dsn that does not set it
+func withForeignKeys(dsn string) string {
+	if strings.Contains(dsn, "_foreign_keys=") || strings.Contains(dsn, "_fk=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_foreign_keys=on"
+	}
+	return dsn + "?_foreign_keys=on"
+}
+
+// dsnDir returns the directory of the database file named by dsn, or "" for in-memory databases
+func dsnDir(dsn string) string {
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" || path == ":memory:" {
+		return ""
+	}
+	return filepath.Dir(path)
+}
+
+// now returns the time of a roster run in UTC
+func (o *Options) now() time.Time {
+	if o.Now != nil {
+		return o.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (o *Options) logf(format string, v ...interface{}) {
+	if o.Log != nil {
+		o.Log.Output(2, fmt.Sprintf(format, v...))
+		return
+	}
+	log.Output(2, fmt.Sprintf(format, v...))
 }
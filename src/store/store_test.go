@@ -0,0 +1,91 @@
+package store
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// testEmails is the staff directory used by test updates
+var testEmails = [][]string{{"email", "name"}, {"a.garcia@aps.edu", "Garcia, Ana"}, {"j.smith@aps.edu", "Smith, John"}}
+
+// testRosterCSV is a Synergy download: Lee in Garcia's Advisory, and Lee and Kim in Smith's Algebra
+const testRosterCSV = `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,"Garcia, Ana",101,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",102,N
+HS,2021,"Kim, Al",2,M,09,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",102,N
+`
+
+// readRoster parses stu415 csv rows as they come from Synergy
+func readRoster(t *testing.T, rows string) types.Stu415s {
+	t.Helper()
+	s415s, err := types.Stu415sFromCSV(strings.NewReader(strings.TrimSpace(rows)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s415s
+}
+
+// newTestRoster returns a migrated roster in a temporary directory that logs nowhere
+func newTestRoster(t *testing.T) *Roster {
+	t.Helper()
+	return openTestRoster(t, filepath.Join(t.TempDir(), "rosters.db"))
+}
+
+// openTestRoster returns a migrated roster at dsn that logs nowhere
+func openTestRoster(t *testing.T, dsn string) *Roster {
+	t.Helper()
+	rs, err := New(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rs.Close() })
+	rs.Log = log.New(io.Discard, "", 0)
+	return rs
+}
+
+func TestWithForeignKeys(t *testing.T) {
+	tests := []struct{ dsn, want string }{
+		{":memory:", ":memory:?_foreign_keys=on"},
+		{"data/rosters.db", "data/rosters.db?_foreign_keys=on"},
+		{"file:r.db?cache=shared", "file:r.db?cache=shared&_foreign_keys=on"},
+		{"file:r.db?_foreign_keys=off", "file:r.db?_foreign_keys=off"},
+		{"r.db?_fk=1", "r.db?_fk=1"},
+	}
+	for _, tt := range tests {
+		if got := withForeignKeys(tt.dsn); got != tt.want {
+			t.Errorf("withForeignKeys(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestForeignKeysCascade(t *testing.T) {
+	rs := newTestRoster(t)
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := rs.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(`SELECT count(*) FROM enrollments`); n != 3 {
+		t.Fatalf("%d enrollments, want 3", n)
+	}
+	if _, err := rs.Exec(`DELETE FROM sections WHERE section_id='200'`); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(`SELECT count(*) FROM enrollments`); n != 1 {
+		t.Errorf("%d enrollments after deleting a section, want 1", n)
+	}
+	if _, err := rs.Exec(`DELETE FROM courses WHERE course_id_and_title='080000 Advisory'`); err == nil {
+		t.Error("deleted a course with sections")
+	}
+}
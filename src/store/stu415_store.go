@@ -3,8 +3,8 @@ package store
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"strings"
+	"time"
 
 	// kosher
 	// Required by law
@@ -13,134 +13,199 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-//  head -n1 stu415.csv | tr '[:upper:]' '[:lower:]' | tr ' ' '_' | tr ',' ' NOT NULL,'
-// Organization Name,School Year,Student Name,Perm ID,Gender,Grade,Term Name,Per,Term,Section ID,Course ID And Title,Meet Days,Teacher,Room,PreScheduled
+// Stu415 rows are staged (stage.go), merged into the normalized tables and selected
+// back in the flat report shape. Queries filter on the joined tables so they can use indexes.
 const (
-	createStu415Table = `CREATE TABLE IF NOT EXISTS stu415(organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, room, prescheduled, sync_id TEXT)`
-	// createStu415Table            = `CREATE TABLE IF NOT EXISTS stu415(student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, teacher, room, sync_id TEXT)`
-	dropStu415Table              = `DROP TABLE IF EXISTS stu415`
-	insertStu415                 = `INSERT INTO stu415(organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, room, prescheduled, sync_id) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
-	selectStu415sByTeacherPeriod = `SELECT * FROM stu415 WHERE teacher=? AND per=?`
-	selectStu415sByTeacher       = `SELECT * FROM stu415 WHERE teacher=?`
-	selectStu415BySection        = `SELECT * FROM stu415 WHERE section_id=?`
-	selectStu415BySID            = `SELECT * FROM stu415 WHERE sync_id=?`
-
-	// Executed by ac.CreateMatthewADV
-	createTmp         = `CREATE TABLE tmp AS SELECT * FROM stu415 WHERE course_id_and_title LIKE "08%00%"`
-	updateTmp         = `UPDATE tmp SET teacher="matthew.kappus@aps.edu"`
-	insertTmpToStu415 = `INSERT INTO stu415 SELECT * FROM tmp`
-	dropTmp           = `DROP TABLE IF EXISTS tmp`
+	selectStu415s                = `SELECT ` + stu415Columns + ` ` + stu415Joins
+	orderStu415s                 = ` ORDER BY c.per_num, c.per, c.course_id_and_title, s.student_name`
+	selectStu415sByTeacherPeriod = selectStu415s + ` WHERE c.teacher=? AND c.per=? AND ` + scopeClause + orderStu415s
+	selectStu415sByTeacher       = selectStu415s + ` WHERE c.teacher=? AND ` + scopeClause + orderStu415s
+	selectStu415BySection        = selectStu415s + ` WHERE c.section_id=? AND ` + scopeClause + orderStu415s
+	selectStu415BySID            = selectStu415s + ` WHERE c.sync_id=? AND ` + scopeClause + orderStu415s
+	selectStu415sByPermID        = selectStu415s + ` WHERE e.perm_id=? AND ` + scopeClause + orderStu415s
+	selectAllStu415s             = selectStu415s + ` WHERE ` + scopeClause + orderStu415s
+	selectPartitionStu415s       = selectStu415s + ` WHERE ` + inStagedPartition + orderStu415s
+	selectGuardStu415s           = selectStu415s + ` WHERE ` + inStagedPartition + ` OR ` + inLatestPartition + orderStu415s
 )
 
 func cleanEmail(email string) string {
 	return strings.TrimSpace(strings.ToLower(email))
 }
 
-// CreateNewStu415AndStaffEmails drops old roster tables
+// CreateNewStu415AndStaffEmails creates missing roster tables and empties the old roster and staff
 func (rs *Roster) CreateNewStu415AndStaffEmails() error {
-	var err error
-	if _, err = rs.Exec(dropStu415Table); err != nil {
+	if _, err := rs.Migrate(); err != nil {
 		return err
 	}
 
-	if _, err = rs.Exec(createStu415Table); err != nil {
-		return err
+	for _, q := range clearRosters {
+		if _, err := rs.Exec(q); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
-// UpdateRosters creates new tables and inserts arguments Stu415 and staff emails
-// TODO: Use this in types.Update
+// UpdateRosters replaces the schools and school years in arguments Stu415 and updates the
+// staff directory with staff emails. Other schools and years are kept.
+// Teacher names are matched to emails and a report of uncertain matches is logged. A data
+// quality pass (types.CheckQuality) is recorded as a check run and summarized, then manual
+// overrides and roster rules (rs.Rules, or the roster_rules table) are applied and reported.
+// Up to rs.MaxBadRows rows missing a key field are logged and skipped; more fail the update
+// with types.RowErrors. Rows are staged, validated and checked by rs.Guard first; if any step
+// fails the previous roster is left intact. A refused update returns a *types.GuardError and
+// a row the database rejects a wrapped *types.RowError
 func (rs *Roster) UpdateRosters(s415s types.Stu415s, emails [][]string) error {
-	if len(s415s) == 0 || len(emails) == 0 {
-		return fmt.Errorf("can't update empty s415s len(%d) or emails len(%d)", len(s415s), len(emails))
-	}
-
-	var err error
-	if rs.CreateNewStu415AndStaffEmails(); err != nil {
+	u, err := rs.prepareUpdate(rs, s415s, emails)
+	if err != nil {
 		return err
 	}
 
-	return rs.InsertStu415s(s415s)
-
-}
-
-// CreateMatthewADV adds matthew.kappus@aps.edu as teacher to advisories
-func (rs *Roster) CreateMatthewADV() error {
-	if _, err := rs.Exec(dropTmp); err != nil {
-		println("dropTmp err")
+	tx, err := rs.Begin()
+	if err != nil {
 		return err
 	}
-	if _, err := rs.Exec(createTmp); err != nil {
-		println("createTmp err")
-		return err
+	diff, err := rs.replaceRosters(tx, u.s415s, u.staff, u.sectionStaff, u.maps)
+	if err != nil {
+		tx.Rollback()
+		if _, ok := err.(*types.GuardError); ok {
+			return err
+		}
+		return fmt.Errorf("roster update rolled back: %w", err)
 	}
-	if _, err := rs.Exec(updateTmp); err != nil {
-		println("updateTmp err")
-		return err
+	if err := recordOverrides(tx, u.overrides, diff.Run.At); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("roster update rolled back: overrides: %v", err)
 	}
-	if _, err := rs.Exec(insertTmpToStu415); err != nil {
-		println("insertTmpToStu415 err")
+	if err := tx.Commit(); err != nil {
 		return err
 	}
-
+	rs.logf("%s", diff.Summary())
 	return nil
 }
 
-func (rs *Roster) initTables() error {
+// updater is what prepareUpdate needs from a store
+type updater interface {
+	recordChecks(findings []*types.Finding, rows int) (*types.CheckReport, error)
+	SelectOverrides() (types.Overrides, error)
+	rules() (types.Rules, error)
+}
 
-	if _, err := rs.Exec(dropTmp); err != nil {
-		return err
+// rosterUpdate is a download ready to replace the partitions it covers
+type rosterUpdate struct {
+	s415s        types.Stu415s
+	staff        []*types.Staff
+	sectionStaff []*types.SectionStaff
+	maps         []*types.SyncIDMapping
+	overrides    *types.OverrideReport
+}
+
+// prepareUpdate runs the steps of UpdateRosters that come before anything is replaced:
+// sync ids, teacher matching, quality checks recorded through u, bad rows, overrides and rules
+func (o *Options) prepareUpdate(u updater, s415s types.Stu415s, emails [][]string) (*rosterUpdate, error) {
+	if len(s415s) == 0 || len(emails) == 0 {
+		return nil, fmt.Errorf("can't update empty s415s len(%d) or emails len(%d)", len(s415s), len(emails))
 	}
-	if _, err := rs.Exec(createStu415Table); err != nil {
-		return err
+
+	if err := s415s.SetSyncIDs(o.SyncIDScheme); err != nil {
+		return nil, err
 	}
 
-	return nil
+	report := s415s.MatchTeachers(types.NewTeacherMatcher(emails, o.TeacherOverrides))
+	o.logf("%s", report)
+
+	checks, err := u.recordChecks(types.CheckQuality(s415s), len(s415s))
+	if err != nil {
+		return nil, fmt.Errorf("record quality checks: %v", err)
+	}
+	o.logf("%s", checks.Summary())
+
+	s415s, err = o.checkRows(s415s)
+	if err != nil {
+		return nil, err
+	}
+
+	// map ids before overrides and rules change rows that older schemes never saw
+	maps := s415s.SyncIDMappings(o.SyncIDScheme)
+	ovs, err := u.SelectOverrides()
+	if err != nil {
+		return nil, fmt.Errorf("load overrides: %v", err)
+	}
+	s415s, ovReport := ovs.Apply(s415s, time.Now())
+	o.logf("%s", ovReport)
+
+	rules, err := u.rules()
+	if err != nil {
+		return nil, fmt.Errorf("load rules: %v", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	s415s, sectionStaff, ruleReport := rules.Apply(s415s)
+	o.logf("%s", ruleReport)
+
+	return &rosterUpdate{
+		s415s:        s415s,
+		staff:        types.StaffFromEmails(emails),
+		sectionStaff: sectionStaff,
+		maps:         maps,
+		overrides:    ovReport,
+	}, nil
 }
 
-// InsertStu415s deletes (old) and inserts provided types.Stu415 slice into table and returns an error
+// InsertStu415s adds the provided types.Stu415 slice to the students, courses, sections and enrollments tables
+// and attaches each section's teacher as its primary staff.
+// Bad rows are skipped up to rs.MaxBadRows as in UpdateRosters; on error nothing is inserted
 func (rs *Roster) InsertStu415s(s415s types.Stu415s) error {
-	tx, err := rs.Begin()
+	s415s, err := rs.checkRows(s415s)
 	if err != nil {
 		return err
 	}
 
-	stmt, err := tx.Prepare(insertStu415)
+	tx, err := rs.Begin()
 	if err != nil {
 		return err
 	}
-
-	for _, s := range s415s {
-
-		// organization_name, school_year, student_name, perm_id, gender, grade, term_name, per, term, section_id, course_id_and_title, meet_days, teacher, room, prescheduled, sync_id
-		if _, err := stmt.Exec(
-			s.OrganizationName,
-			s.SchoolYear,
-			s.StudentName,
-			s.PermID,
-			s.Gender,
-			s.Grade,
-			s.TermName,
-			s.Per,
-			s.Term,
-			s.SectionID,
-			s.CourseIDAndTitle,
-			s.MeetDays,
-			s.Teacher,
-			s.Room,
-			s.Prescheduled,
-			s.SyncID,
-		); err != nil {
-			log.Fatal(err)
-		}
-
+	if err := execAll(tx, clearStage); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := stageStu415s(tx, s415s); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := execAll(tx, mergeStage); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("merge: %v", err)
+	}
+	if _, err := tx.Exec(insertPrimarySectionStaff); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("section staff: %v", err)
+	}
+	if err := recordStaffHistory(tx, rs.now()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("history: %v", err)
+	}
+	if err := rs.rebuildSearch(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("search index: %v", err)
 	}
-
 	return tx.Commit()
 }
 
+// checkRows drops rows missing a key field, logging each, or returns types.RowErrors
+// if there are more than o.MaxBadRows
+func (o *Options) checkRows(s415s types.Stu415s) (types.Stu415s, error) {
+	good, bad, err := s415s.CheckRows(o.MaxBadRows)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range bad {
+		o.logf("skipped %v", e)
+	}
+	return good, nil
+}
+
 func scan415(rows *sql.Rows) (*types.Stu415, error) {
 	s := new(types.Stu415)
 
@@ -158,6 +223,7 @@ func scan415(rows *sql.Rows) (*types.Stu415, error) {
 		&s.CourseIDAndTitle,
 		&s.MeetDays,
 		&s.Teacher,
+		&s.TeacherName,
 		&s.Room,
 		&s.Prescheduled,
 		&s.SyncID,
@@ -165,104 +231,177 @@ func scan415(rows *sql.Rows) (*types.Stu415, error) {
 	return s, err
 }
 
-// SelectClassesByTeacher gets stu415s by provided teacher email and returns a Roster list or error
-func (rs *Roster) SelectClassesByTeacher(email string) ([]*types.Class, error) {
-	s415s, err := rs.SelectStu415sByTeacher(email)
+// SelectClassesByTeacher returns every class the staff member with email is attached to,
+// with their role in it, or error. Query methods take an optional scope; see types.Scope
+func (rs *Roster) SelectClassesByTeacher(email string, scope ...types.Scope) ([]*types.Class, error) {
+	email = cleanEmail(email)
+	args, err := rs.scopeArgs(scope)
 	if err != nil {
 		return nil, err
 	}
-	return types.Stu415sToClasses(s415s), nil
+	s415s, err := rs.selectStu415s(selectStu415sByStaff, append([]interface{}{email}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := rs.selectStaffRoles(email)
+	if err != nil {
+		return nil, err
+	}
+	classes := types.Stu415sToClasses(s415s)
+	setRoles(classes, roles)
+	return classes, nil
+}
+
+// SelectCrossListedByTeacher returns a teacher's classes grouped by shared period and room
+func (rs *Roster) SelectCrossListedByTeacher(email string, scope ...types.Scope) ([]*types.ClassGroup, error) {
+	classes, err := rs.SelectClassesByTeacher(email, scope...)
+	if err != nil {
+		return nil, err
+	}
+	return types.GroupCrossListed(classes), nil
 }
 
 // SelectClassByID takes a syncid and returns a class or error if not found
-func (rs *Roster) SelectClassByID(sid string) (*types.Class, error) {
-	s415s, err := rs.SelectStu415sBySyncID(sid)
+// Ids from older sync id schemes are resolved through sync_id_map
+func (rs *Roster) SelectClassByID(sid string, scope ...types.Scope) (*types.Class, error) {
+	s415s, err := rs.SelectStu415sBySyncID(sid, scope...)
 	if err != nil {
 		return nil, err
 	}
+	if len(s415s) == 0 {
+		newID, err := rs.ResolveSyncID(sid)
+		if err != nil {
+			return nil, err
+		}
+		if newID != sid {
+			if s415s, err = rs.SelectStu415sBySyncID(newID, scope...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(s415s) == 0 {
+		return nil, sql.ErrNoRows
+	}
 	return types.Stu415sToClass(s415s), nil
 }
 
 // SelectStu415sBySyncID returns students by their sync id
-func (rs *Roster) SelectStu415sBySyncID(sid string) (types.Stu415s, error) {
-	stmt, err := rs.Prepare(selectStu415BySID)
+func (rs *Roster) SelectStu415sBySyncID(sid string, scope ...types.Scope) (types.Stu415s, error) {
+	args, err := rs.scopeArgs(scope)
 	if err != nil {
 		return nil, err
 	}
+	return rs.selectStu415s(selectStu415BySID, append([]interface{}{sid}, args...)...)
+}
 
-	rows, err := stmt.Query(sid)
+// SelectStu415sByTeacherPeriod returns stu415s where provided email is the Teacher during per
+func (rs *Roster) SelectStu415sByTeacherPeriod(email, per string, scope ...types.Scope) (types.Stu415s, error) {
+	args, err := rs.scopeArgs(scope)
 	if err != nil {
 		return nil, err
 	}
-	s415s := make(types.Stu415s, 0)
-	var scanErr string
-	var scanCount int
-	for rows.Next() {
-		s, err := scan415(rows)
-		if err != nil {
-			scanCount++
-			scanErr += fmt.Sprintf("Scan Error: %v\n", err)
-		}
-		s415s = append(s415s, s)
-	}
-	if scanCount > 0 {
-		return nil, fmt.Errorf("SelectStu415sBySyncID encountered %d errors:\n%s", scanCount, scanErr)
-	}
-	return s415s, nil
+	return rs.selectStu415s(selectStu415sByTeacherPeriod, append([]interface{}{email, per}, args...)...)
 }
 
-// SelectStu415sByTeacherPeriod returns stu415s where provided email is the Teacher,
-func (rs *Roster) SelectStu415sByTeacherPeriod(email, per string) (s415s types.Stu415s, err error) {
-	stmt, err := rs.Prepare(selectStu415sByTeacherPeriod)
+// SelectStu415sByTeacher returns stu415s where provided email is the Teacher
+func (rs *Roster) SelectStu415sByTeacher(email string, scope ...types.Scope) (types.Stu415s, error) {
+	args, err := rs.scopeArgs(scope)
 	if err != nil {
 		return nil, err
 	}
+	return rs.selectStu415s(selectStu415sByTeacher, append([]interface{}{email}, args...)...)
+}
 
-	rows, err := stmt.Query(email, per)
+// SelectStu415sBySection returns stu415s in sections with the Synergy section id
+func (rs *Roster) SelectStu415sBySection(sectionID string, scope ...types.Scope) (types.Stu415s, error) {
+	args, err := rs.scopeArgs(scope)
 	if err != nil {
 		return nil, err
 	}
+	return rs.selectStu415s(selectStu415BySection, append([]interface{}{sectionID}, args...)...)
+}
+
+// queryer is satisfied by *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
 
-	var scanErr string
-	var scanCount int
+// queryStu415s scans every row returned by query on q
+func queryStu415s(q queryer, query string, args ...interface{}) (types.Stu415s, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stu415s: %w", err)
+	}
+	return scanStu415s(rows)
+}
+
+// selectStu415s scans every row returned by query, prepared once on rs
+func (rs *Roster) selectStu415s(query string, args ...interface{}) (types.Stu415s, error) {
+	rows, err := rs.query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stu415s: %w", err)
+	}
+	return scanStu415s(rows)
+}
+
+// scanStu415s scans and closes rows
+func scanStu415s(rows *sql.Rows) (types.Stu415s, error) {
+	defer rows.Close()
+
+	s415s := make(types.Stu415s, 0)
 	for rows.Next() {
 		s, err := scan415(rows)
 		if err != nil {
-			scanCount++
-			scanErr += fmt.Sprintf("Scan Error: %v\n", err)
+			return nil, fmt.Errorf("scan stu415 row %d: %w", len(s415s)+1, err)
 		}
 		s415s = append(s415s, s)
 	}
-	if scanCount > 0 {
-		return nil, fmt.Errorf("SelectStu415sByTeacher encountered %d errors:\n%s", scanCount, scanErr)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read stu415s: %w", err)
 	}
 	return s415s, nil
 }
 
-// SelectStu415sByTeacher returns stu415s where provided email is the Teacher,
-func (rs *Roster) SelectStu415sByTeacher(email string) (s415s types.Stu415s, err error) {
-	stmt, err := rs.Prepare(selectStu415sByTeacher)
+// SelectScheduleByStudent returns a student with their enrollments ordered by period
+// permID may be bare or include the @aps.edu domain
+func (rs *Roster) SelectScheduleByStudent(permID string, scope ...types.Scope) (*types.Student, error) {
+	args, err := rs.scopeArgs(scope)
 	if err != nil {
 		return nil, err
 	}
+	s415s, err := rs.selectStu415s(selectStu415sByPermID, append([]interface{}{types.PermIDEmail(permID)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	students := types.Stu415sToStudents(s415s)
+	if len(students) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return students[0], nil
+}
 
-	rows, err := stmt.Query(email)
+// SelectAllStudents returns every student with their schedule
+func (rs *Roster) SelectAllStudents(scope ...types.Scope) ([]*types.Student, error) {
+	args, err := rs.scopeArgs(scope)
 	if err != nil {
 		return nil, err
 	}
+	s415s, err := rs.selectStu415s(selectAllStu415s, args...)
+	if err != nil {
+		return nil, err
+	}
+	return types.Stu415sToStudents(s415s), nil
+}
 
-	var scanErr string
-	var scanCount int
-	for rows.Next() {
-		s, err := scan415(rows)
-		if err != nil {
-			scanCount++
-			scanErr += fmt.Sprintf("Scan Error: %v\n", err)
-		}
-		s415s = append(s415s, s)
+// SelectAllTeachers returns every teacher on the roster with their sections
+func (rs *Roster) SelectAllTeachers(scope ...types.Scope) ([]*types.Teacher, error) {
+	args, err := rs.scopeArgs(scope)
+	if err != nil {
+		return nil, err
 	}
-	if scanCount > 0 {
-		return nil, fmt.Errorf("SelectStu415sByTeacher encountered %d errors:\n%s", scanCount, scanErr)
+	s415s, err := rs.selectStu415s(selectAllStu415s, args...)
+	if err != nil {
+		return nil, err
 	}
-	return s415s, nil
+	return types.Stu415sToTeachers(s415s), nil
 }
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+func TestUpdateRostersReplaces(t *testing.T) {
+	rs := newTestRoster(t)
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+	// Kim moves from Algebra to Garcia's Bio
+	if err := rs.UpdateRosters(readRoster(t, `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,"Garcia, Ana",101,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",102,N
+HS,2021,"Kim, Al",2,M,09,Year,3,YR,300,120000 Bio,MTWRF,"Garcia, Ana",103,N
+`), testEmails); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		teacher string
+		courses []string
+	}{
+		{"j.smith@aps.edu", []string{"110000 Algebra"}},
+		{"a.garcia@aps.edu", []string{"080000 Advisory", "120000 Bio"}},
+	}
+	for _, tt := range tests {
+		rows, err := rs.SelectStu415sByTeacher(tt.teacher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var courses []string
+		for _, s := range rows {
+			courses = append(courses, s.CourseIDAndTitle)
+		}
+		if strings.Join(courses, ",") != strings.Join(tt.courses, ",") {
+			t.Errorf("%s teaches %q, want %q", tt.teacher, courses, tt.courses)
+		}
+	}
+}
+
+func TestUpdateRostersRollsBack(t *testing.T) {
+	rs := newTestRoster(t)
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+	// a row without a course fails validation and nothing is replaced
+	err := rs.UpdateRosters(readRoster(t, `
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,"Smith, John",102,N
+HS,2021,"Kim, Al",2,M,09,Year,3,YR,300,,MTWRF,"Garcia, Ana",103,N
+`), testEmails)
+	if err == nil {
+		t.Fatal("updated with a row missing its course")
+	}
+	rows, err := rs.SelectStu415sByTeacher("j.smith@aps.edu")
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("after a failed update Smith has %d rows, %v", len(rows), err)
+	}
+	if rows, err := rs.SelectStu415sByTeacher("a.garcia@aps.edu"); err != nil || len(rows) != 1 || rows[0].CourseIDAndTitle != "080000 Advisory" {
+		t.Fatalf("after a failed update Garcia has %v, %v", rows, err)
+	}
+}
+
+func TestUpdateRostersRefused(t *testing.T) {
+	rs := newTestRoster(t)
+	first := time.Date(2021, 9, 1, 6, 0, 0, 0, time.UTC)
+	rs.Now = func() time.Time { return first }
+	if err := rs.UpdateRosters(readRoster(t, testRosterCSV), testEmails); err != nil {
+		t.Fatal(err)
+	}
+
+	// a download missing Smith's Algebra halves the roster
+	truncated := `HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,"Garcia, Ana",101,N`
+	second := first.AddDate(0, 0, 1)
+	rs.Now = func() time.Time { return second }
+	err := rs.UpdateRosters(readRoster(t, truncated), testEmails)
+	var ge *types.GuardError
+	if !errors.As(err, &ge) || len(ge.Report.Violations) == 0 {
+		t.Fatalf("truncated roster: %v, want a guard error", err)
+	}
+	if rows, err := rs.SelectStu415sByTeacher("j.smith@aps.edu"); err != nil || len(rows) != 2 {
+		t.Fatalf("after a refused update Smith has %d rows, %v", len(rows), err)
+	}
+	if runs, err := rs.SelectRosterRuns(); err != nil || len(runs) != 1 {
+		t.Fatalf("%d runs after a refused update, %v", len(runs), err)
+	}
+	if rows, err := rs.SelectStu415sByTeacherAsOf("j.smith@aps.edu", second); err != nil || len(rows) != 2 {
+		t.Fatalf("history after a refused update has %d rows, %v", len(rows), err)
+	}
+
+	rs.Force = true
+	if err := rs.UpdateRosters(readRoster(t, truncated), testEmails); err != nil {
+		t.Fatalf("forced update: %v", err)
+	}
+	if rows, err := rs.SelectStu415sByTeacher("j.smith@aps.edu"); err != nil || len(rows) != 0 {
+		t.Fatalf("after a forced update Smith has %d rows, %v", len(rows), err)
+	}
+}
+
+func TestInsertStu415sAttachesTeachers(t *testing.T) {
+	rs := newTestRoster(t)
+	s415s := readRoster(t, `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,a.garcia@aps.edu,101,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,j.smith@aps.edu,102,N
+HS,2021,"Kim, Al",2,M,09,Year,2,YR,200,110000 Algebra,MTWRF,j.smith@aps.edu,102,N
+`)
+	if err := s415s.SetSyncIDs(rs.SyncIDScheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.InsertStu415s(s415s); err != nil {
+		t.Fatal(err)
+	}
+	classes, err := rs.SelectClassesByTeacher("j.smith@aps.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(classes) != 1 || len(classes[0].Students) != 2 || classes[0].Role != types.RolePrimary {
+		t.Fatalf("classes %+v, want Algebra with 2 students", classes)
+	}
+}
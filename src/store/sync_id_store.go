@@ -0,0 +1,55 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/matthewkappus/rosterUpdate/src/types"
+)
+
+// sync_id_map is kept across updates so links made with older ids keep working
+const (
+	insertSyncIDMap = `INSERT INTO sync_id_map(old_id, new_id, scheme) VALUES(?,?,?) ON CONFLICT DO NOTHING`
+	selectSyncIDMap = `SELECT new_id FROM sync_id_map WHERE old_id=? ORDER BY created_at DESC LIMIT 1`
+)
+
+// InsertSyncIDMappings records old to new sync ids
+func (rs *Roster) InsertSyncIDMappings(maps []*types.SyncIDMapping) error {
+	tx, err := rs.Begin()
+	if err != nil {
+		return err
+	}
+	if err := insertSyncIDMappings(tx, maps); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertSyncIDMappings(tx *sql.Tx, maps []*types.SyncIDMapping) error {
+	stmt, err := tx.Prepare(insertSyncIDMap)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, m := range maps {
+		if _, err := stmt.Exec(m.OldID, m.NewID, m.Scheme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveSyncID returns the current sync id for an id made by an older scheme.
+// Ids without a mapping are returned unchanged
+func (rs *Roster) ResolveSyncID(sid string) (string, error) {
+	var newID string
+	err := rs.QueryRow(selectSyncIDMap, sid).Scan(&newID)
+	switch {
+	case err == sql.ErrNoRows:
+		return sid, nil
+	case err != nil:
+		return "", err
+	}
+	return newID, nil
+}
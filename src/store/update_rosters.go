@@ -8,8 +8,12 @@ import (
 
 // DownloadRosters prompts user for Synergy Credentials, downloads Stu415s and emails, and
 // returns error if the db can't be updated or provided wait time exceeded
-func (r Roster) DownloadRosters(wait time.Duration, synergyUser, synergyPassword string) error {
+func (r *Roster) DownloadRosters(wait time.Duration, synergyUser, synergyPassword string) error {
+	return downloadRosters(r, wait, synergyUser, synergyPassword)
+}
 
+// downloadRosters downloads Stu415s and emails from Synergy and updates rs with them
+func downloadRosters(rs RosterStore, wait time.Duration, synergyUser, synergyPassword string) error {
 	ac, err := synergy.NewClient(synergyUser, synergyPassword, wait)
 	if err != nil {
 		return err
@@ -25,18 +29,5 @@ func (r Roster) DownloadRosters(wait time.Duration, synergyUser, synergyPassword
 		return err
 	}
 
-	if err := s415s.SetSyncIDs(); err != nil {
-		return err
-	}
-
-	if err := r.CreateNewStu415AndStaffEmails(); err != nil {
-		return err
-	}
-	s415s.TeacherNameToEmail(emails)
-
-	if err := r.InsertStu415s(s415s); err != nil {
-		return err
-	}
-
-	return r.CreateMatthewADV()
+	return rs.UpdateRosters(s415s, emails)
 }
@@ -0,0 +1,200 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Section size statuses
+const (
+	SizeOver  = "over"
+	SizeUnder = "under"
+)
+
+// SizeCaps bound section enrollment; a 0 bound is not checked
+type SizeCaps struct {
+	Min int `json:"min,omitempty"`
+	Max int `json:"max,omitempty"`
+}
+
+// DefaultSizeCaps flag sections that are likely mis-scheduled
+var DefaultSizeCaps = SizeCaps{Min: 5, Max: 35}
+
+// Check returns SizeOver or SizeUnder if students is outside the caps, or ""
+func (c SizeCaps) Check(students int) string {
+	switch {
+	case c.Max > 0 && students > c.Max:
+		return SizeOver
+	case c.Min > 0 && students < c.Min:
+		return SizeUnder
+	}
+	return ""
+}
+
+// SectionSize is one section's enrollment with its grade and gender breakdowns
+type SectionSize struct {
+	SyncID   string         `json:"sync_id"`
+	School   string         `json:"school"`
+	Year     string         `json:"year"`
+	Course   string         `json:"course"`
+	Per      string         `json:"per"`
+	Teacher  string         `json:"teacher"`
+	Room     string         `json:"room,omitempty"`
+	Students int            `json:"students"`
+	Grades   map[string]int `json:"grades,omitempty"`
+	Genders  map[string]int `json:"genders,omitempty"`
+	Status   string         `json:"status,omitempty"`
+}
+
+// TeacherLoad counts a teacher's sections and distinct students
+type TeacherLoad struct {
+	Teacher  string `json:"teacher"`
+	Name     string `json:"name,omitempty"`
+	Sections int    `json:"sections"`
+	Students int    `json:"students"`
+}
+
+// PeriodCount counts the sections meeting and distinct students scheduled in a period
+type PeriodCount struct {
+	Per      string `json:"per"`
+	Sections int    `json:"sections"`
+	Students int    `json:"students"`
+}
+
+// Analytics summarizes a roster's section sizes, teacher loads and periods
+type Analytics struct {
+	Caps     SizeCaps       `json:"caps"`
+	Sections []*SectionSize `json:"sections"`
+	Teachers []*TeacherLoad `json:"teachers"`
+	Periods  []*PeriodCount `json:"periods"`
+}
+
+// Analyze counts s415s by section, teacher and period and checks section sizes against caps.
+// The blank student rows of empty sections count the section but no student.
+// Sections and periods are ordered by period, teachers by name
+func Analyze(s415s Stu415s, caps SizeCaps) *Analytics {
+	a := &Analytics{Caps: caps, Sections: []*SectionSize{}, Teachers: []*TeacherLoad{}, Periods: []*PeriodCount{}}
+	sections := make(map[string]*SectionSize)
+	sectionStudents := make(map[string]map[string]bool)
+	teachers := make(map[string]*TeacherLoad)
+	teacherStudents := make(map[string]map[string]bool)
+	teacherSections := make(map[string]map[string]bool)
+	periods := make(map[string]*PeriodCount)
+	periodStudents := make(map[string]map[string]bool)
+	periodSections := make(map[string]map[string]bool)
+
+	for _, s := range s415s {
+		k := sectionKey(s)
+		sec, ok := sections[k]
+		if !ok {
+			sec = &SectionSize{
+				SyncID:  s.SyncID,
+				School:  s.OrganizationName,
+				Year:    s.SchoolYear,
+				Course:  s.CourseIDAndTitle,
+				Per:     s.Per,
+				Teacher: s.Teacher,
+				Room:    s.Room,
+				Grades:  make(map[string]int),
+				Genders: make(map[string]int),
+			}
+			sections[k] = sec
+			sectionStudents[k] = make(map[string]bool)
+			a.Sections = append(a.Sections, sec)
+		}
+		student := hasStudent(s)
+		if student && !sectionStudents[k][s.PermID] {
+			sectionStudents[k][s.PermID] = true
+			sec.Students++
+			sec.Grades[s.Grade]++
+			sec.Genders[s.Gender]++
+		}
+
+		t, ok := teachers[s.Teacher]
+		if !ok {
+			t = &TeacherLoad{Teacher: s.Teacher, Name: s.TeacherName}
+			teachers[s.Teacher] = t
+			teacherStudents[s.Teacher] = make(map[string]bool)
+			teacherSections[s.Teacher] = make(map[string]bool)
+			a.Teachers = append(a.Teachers, t)
+		}
+		if student {
+			teacherStudents[s.Teacher][s.PermID] = true
+		}
+		teacherSections[s.Teacher][k] = true
+
+		p, ok := periods[s.Per]
+		if !ok {
+			p = &PeriodCount{Per: s.Per}
+			periods[s.Per] = p
+			periodStudents[s.Per] = make(map[string]bool)
+			periodSections[s.Per] = make(map[string]bool)
+			a.Periods = append(a.Periods, p)
+		}
+		if student {
+			periodStudents[s.Per][s.PermID] = true
+		}
+		periodSections[s.Per][k] = true
+	}
+
+	for _, sec := range a.Sections {
+		sec.Status = caps.Check(sec.Students)
+	}
+	for _, t := range a.Teachers {
+		t.Sections, t.Students = len(teacherSections[t.Teacher]), len(teacherStudents[t.Teacher])
+	}
+	for _, p := range a.Periods {
+		p.Sections, p.Students = len(periodSections[p.Per]), len(periodStudents[p.Per])
+	}
+
+	sort.SliceStable(a.Sections, func(i, j int) bool {
+		pi, pj := ParsePeriod(a.Sections[i].Per), ParsePeriod(a.Sections[j].Per)
+		if pi != pj {
+			return pi.Less(pj)
+		}
+		if a.Sections[i].Course != a.Sections[j].Course {
+			return a.Sections[i].Course < a.Sections[j].Course
+		}
+		return a.Sections[i].Teacher < a.Sections[j].Teacher
+	})
+	sort.SliceStable(a.Teachers, func(i, j int) bool {
+		if a.Teachers[i].Name != a.Teachers[j].Name {
+			return a.Teachers[i].Name < a.Teachers[j].Name
+		}
+		return a.Teachers[i].Teacher < a.Teachers[j].Teacher
+	})
+	sort.SliceStable(a.Periods, func(i, j int) bool {
+		return ParsePeriod(a.Periods[i].Per).Less(ParsePeriod(a.Periods[j].Per))
+	})
+	return a
+}
+
+// OutOfBounds returns the sections over or under the caps
+func (a *Analytics) OutOfBounds() []*SectionSize {
+	out := make([]*SectionSize, 0)
+	for _, sec := range a.Sections {
+		if sec.Status != "" {
+			out = append(out, sec)
+		}
+	}
+	return out
+}
+
+// FormatCounts renders counts as "key:n" pairs in key order, e.g. "09:12 10:3"
+func FormatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		label := k
+		if label == "" {
+			label = "?"
+		}
+		pairs[i] = fmt.Sprintf("%s:%d", label, counts[k])
+	}
+	return strings.Join(pairs, " ")
+}
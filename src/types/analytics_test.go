@@ -0,0 +1,100 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// analyticsRoster has Advisory over and Lab under a cap of 2, and Algebra at HS and MS with a repeated row
+const analyticsRoster = `
+HS,2021,"Cruz, Ava",3,F,11,Year,HR,YR,900,Lab,MTWRF,"A, Al",101,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,Algebra,MTWRF,"B, Bea",102,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,Algebra,MTWRF,"B, Bea",102,N
+HS,2021,"Kim, Al",2,,10,Year,2,YR,200,Algebra,MTWRF,"B, Bea",102,N
+MS,2021,"Cho, Di",4,M,07,Year,2,YR,200,Algebra,MTWRF,"B, Bea",202,N
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,Advisory,MTWRF,"A, Al",101,N
+HS,2021,"Kim, Al",2,,10,Year,1,YR,100,Advisory,MTWRF,"A, Al",101,N
+HS,2021,"Cruz, Ava",3,F,11,Year,1,YR,100,Advisory,MTWRF,"A, Al",101,N
+`
+
+func TestAnalyze(t *testing.T) {
+	a := Analyze(readStu415s(t, analyticsRoster), SizeCaps{Min: 2, Max: 2})
+
+	var sections []string
+	for _, s := range a.Sections {
+		sections = append(sections, fmt.Sprintf("%s %s %s %d %s %s %s", s.School, s.Per, s.Course, s.Students, FormatCounts(s.Grades), FormatCounts(s.Genders), s.Status))
+	}
+	want := []string{
+		"HS 1 Advisory 3 09:1 10:1 11:1 ?:1 F:2 over",
+		"HS 2 Algebra 2 09:1 10:1 ?:1 F:1 ",
+		"MS 2 Algebra 1 07:1 M:1 under",
+		"HS HR Lab 1 11:1 F:1 under",
+	}
+	if !reflect.DeepEqual(sections, want) {
+		t.Errorf("sections %q, want %q", sections, want)
+	}
+
+	var teachers []string
+	for _, l := range a.Teachers {
+		teachers = append(teachers, fmt.Sprintf("%s %d %d", l.Teacher, l.Sections, l.Students))
+	}
+	if want := []string{"A, Al 2 3", "B, Bea 2 3"}; !reflect.DeepEqual(teachers, want) {
+		t.Errorf("teachers %q, want %q", teachers, want)
+	}
+
+	var periods []string
+	for _, p := range a.Periods {
+		periods = append(periods, fmt.Sprintf("%s %d %d", p.Per, p.Sections, p.Students))
+	}
+	if want := []string{"1 1 3", "2 2 3", "HR 1 1"}; !reflect.DeepEqual(periods, want) {
+		t.Errorf("periods %q, want %q", periods, want)
+	}
+
+	if n := len(a.OutOfBounds()); n != 3 {
+		t.Errorf("%d sections out of bounds, want 3", n)
+	}
+}
+
+func TestAnalyzeEmptySection(t *testing.T) {
+	// Synergy lists an empty section as one row without a student
+	a := Analyze(readStu415s(t, `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,Advisory,MTWRF,"A, Al",101,N
+HS,2021,,,,,Year,2,YR,200,Algebra,MTWRF,"A, Al",102,N
+`), DefaultSizeCaps)
+
+	var sections []string
+	for _, s := range a.Sections {
+		sections = append(sections, fmt.Sprintf("%s %d %s %s", s.Course, s.Students, FormatCounts(s.Grades), s.Status))
+	}
+	if want := []string{"Advisory 1 09:1 under", "Algebra 0  under"}; !reflect.DeepEqual(sections, want) {
+		t.Errorf("sections %q, want %q", sections, want)
+	}
+	if l := a.Teachers[0]; l.Sections != 2 || l.Students != 1 {
+		t.Errorf("teacher load %+v, want 2 sections and 1 student", l)
+	}
+	if p := a.Periods[1]; p.Sections != 1 || p.Students != 0 {
+		t.Errorf("period %+v, want 1 section without students", p)
+	}
+}
+
+func TestSizeCapsCheck(t *testing.T) {
+	tests := []struct {
+		caps     SizeCaps
+		students int
+		want     string
+	}{
+		{DefaultSizeCaps, 20, ""},
+		{DefaultSizeCaps, 36, SizeOver},
+		{DefaultSizeCaps, 4, SizeUnder},
+		{DefaultSizeCaps, 0, SizeUnder},
+		{SizeCaps{}, 0, ""},
+		{SizeCaps{Max: 10}, 1, ""},
+		{SizeCaps{Min: 10}, 100, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.caps.Check(tt.students); got != tt.want {
+			t.Errorf("%+v.Check(%d) = %q, want %q", tt.caps, tt.students, got, tt.want)
+		}
+	}
+}
@@ -11,7 +11,7 @@ func (s *Stu415) Parse(r []string) error {
 	s.OrganizationName = r[0]
 	s.SchoolYear = r[1]
 	s.StudentName = r[2]
-	s.PermID = r[3] + "@aps.edu"
+	s.PermID = r[3] + PermIDDomain
 	s.Gender = r[4]
 	s.Grade = r[5]
 	s.TermName = r[6]
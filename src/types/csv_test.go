@@ -0,0 +1,46 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+// readStu415s parses stu415 csv rows as they come from Synergy and sets their sync ids
+func readStu415s(t *testing.T, rows string) Stu415s {
+	t.Helper()
+	s415s, err := Stu415sFromCSV(strings.NewReader(strings.TrimSpace(rows)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s415s.SetSyncIDs(SyncIDScheme{}); err != nil {
+		t.Fatal(err)
+	}
+	return s415s
+}
+
+func TestStu415sFromCSV(t *testing.T) {
+	s415s, err := Stu415sFromCSV(strings.NewReader(`HS,2021,"Lee, Bo",1001,F,09,Semester 1,3A,S1,100,110000 Algebra,MWF,"Smith, John",102,Y`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stu415{
+		OrganizationName: "HS",
+		SchoolYear:       "2021",
+		StudentName:      "Lee, Bo",
+		PermID:           "1001" + PermIDDomain,
+		Gender:           "F",
+		Grade:            "09",
+		TermName:         "Semester 1",
+		Per:              "3A",
+		Term:             "S1",
+		SectionID:        "100",
+		CourseIDAndTitle: "110000 Algebra",
+		MeetDays:         "MWF",
+		Teacher:          "Smith, John",
+		Room:             "102",
+		Prescheduled:     "Y",
+	}
+	if len(s415s) != 1 || *s415s[0] != want {
+		t.Errorf("parsed %+v, want %+v", s415s, want)
+	}
+}
@@ -0,0 +1,217 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Roster change kinds
+const (
+	EnrollmentAdded   = "enrollment_added"
+	EnrollmentRemoved = "enrollment_removed"
+	TeacherChanged    = "teacher_changed"
+	RoomChanged       = "room_changed"
+	SectionAdded      = "section_added"
+	SectionRemoved    = "section_removed"
+)
+
+// ChangeKinds lists change kinds in report order
+var ChangeKinds = []string{SectionAdded, SectionRemoved, TeacherChanged, RoomChanged, EnrollmentAdded, EnrollmentRemoved}
+
+type (
+	// RosterChange is one difference between two rosters.
+	// Old and New hold the changed value for teacher and room changes
+	RosterChange struct {
+		Kind        string `json:"kind"`
+		School      string `json:"school,omitempty"`
+		Year        string `json:"year,omitempty"`
+		SyncID      string `json:"sync_id,omitempty"`
+		Course      string `json:"course,omitempty"`
+		Per         string `json:"per,omitempty"`
+		Teacher     string `json:"teacher,omitempty"`
+		PermID      string `json:"perm_id,omitempty"`
+		StudentName string `json:"student_name,omitempty"`
+		Old         string `json:"old,omitempty"`
+		New         string `json:"new,omitempty"`
+	}
+
+	// RosterRun is one roster update
+	RosterRun struct {
+		ID   int64     `json:"id"`
+		At   time.Time `json:"at"`
+		Rows int       `json:"rows"`
+	}
+
+	// RosterDiff holds the changes an update made to the previous roster
+	RosterDiff struct {
+		Run     RosterRun       `json:"run"`
+		Changes []*RosterChange `json:"changes,omitempty"`
+	}
+
+	// diffSection summarizes a section's rows for comparison
+	diffSection struct {
+		first    *Stu415
+		teachers []string
+		rooms    []string
+		students map[string]*Stu415
+	}
+)
+
+// diffSections groups s415s by school, year and sync id; a sync id may repeat across partitions
+func diffSections(s415s Stu415s) map[string]*diffSection {
+	sections := make(map[string]*diffSection)
+	for _, s := range s415s {
+		k := strings.Join([]string{s.OrganizationName, s.SchoolYear, s.SyncID}, syncIDSep)
+		ds, ok := sections[k]
+		if !ok {
+			ds = &diffSection{first: s, students: make(map[string]*Stu415)}
+			sections[k] = ds
+		}
+		ds.teachers = appendUnique(ds.teachers, s.Teacher)
+		ds.rooms = appendUnique(ds.rooms, s.Room)
+		if _, ok := ds.students[s.PermID]; !ok {
+			ds.students[s.PermID] = s
+		}
+	}
+	for _, ds := range sections {
+		sort.Strings(ds.teachers)
+		sort.Strings(ds.rooms)
+	}
+	return sections
+}
+
+func sectionChange(kind string, ds *diffSection) *RosterChange {
+	return &RosterChange{
+		Kind:    kind,
+		School:  ds.first.OrganizationName,
+		Year:    ds.first.SchoolYear,
+		SyncID:  ds.first.SyncID,
+		Course:  ds.first.CourseIDAndTitle,
+		Per:     ds.first.Per,
+		Teacher: strings.Join(ds.teachers, ","),
+	}
+}
+
+func enrollmentChange(kind string, ds *diffSection, s *Stu415) *RosterChange {
+	c := sectionChange(kind, ds)
+	c.PermID = s.PermID
+	c.StudentName = s.StudentName
+	return c
+}
+
+// DiffStu415s compares sections by school, year and SyncID and enrollments by section and PermID.
+// Changes are ordered by kind, period, course and student
+func DiffStu415s(old, new Stu415s) []*RosterChange {
+	before, after := diffSections(old), diffSections(new)
+	var changes []*RosterChange
+
+	for k, a := range after {
+		b, ok := before[k]
+		if !ok {
+			changes = append(changes, sectionChange(SectionAdded, a))
+			for _, s := range a.students {
+				changes = append(changes, enrollmentChange(EnrollmentAdded, a, s))
+			}
+			continue
+		}
+		if bt, at := strings.Join(b.teachers, ","), strings.Join(a.teachers, ","); bt != at {
+			c := sectionChange(TeacherChanged, a)
+			c.Old, c.New = bt, at
+			changes = append(changes, c)
+		}
+		if br, ar := strings.Join(b.rooms, ","), strings.Join(a.rooms, ","); br != ar {
+			c := sectionChange(RoomChanged, a)
+			c.Old, c.New = br, ar
+			changes = append(changes, c)
+		}
+		for perm, s := range a.students {
+			if _, ok := b.students[perm]; !ok {
+				changes = append(changes, enrollmentChange(EnrollmentAdded, a, s))
+			}
+		}
+		for perm, s := range b.students {
+			if _, ok := a.students[perm]; !ok {
+				changes = append(changes, enrollmentChange(EnrollmentRemoved, b, s))
+			}
+		}
+	}
+	for k, b := range before {
+		if _, ok := after[k]; ok {
+			continue
+		}
+		changes = append(changes, sectionChange(SectionRemoved, b))
+		for _, s := range b.students {
+			changes = append(changes, enrollmentChange(EnrollmentRemoved, b, s))
+		}
+	}
+
+	SortChanges(changes)
+	return changes
+}
+
+// SortChanges orders changes by kind, period, course, section and student
+func SortChanges(changes []*RosterChange) {
+	order := make(map[string]int, len(ChangeKinds))
+	for i, k := range ChangeKinds {
+		order[k] = i
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if pa, pb := ParsePeriod(a.Per), ParsePeriod(b.Per); pa != pb {
+			return pa.Less(pb)
+		}
+		if a.Course != b.Course {
+			return a.Course < b.Course
+		}
+		if a.School != b.School {
+			return a.School < b.School
+		}
+		if a.Year != b.Year {
+			return a.Year < b.Year
+		}
+		if a.SyncID != b.SyncID {
+			return a.SyncID < b.SyncID
+		}
+		return a.StudentName < b.StudentName
+	})
+}
+
+// Count returns the number of changes of kind
+func (d *RosterDiff) Count(kind string) int {
+	n := 0
+	for _, c := range d.Changes {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary counts changes by kind, e.g. "run 4: 2 section_added, 31 enrollment_added"
+func (d *RosterDiff) Summary() string {
+	var parts []string
+	for _, k := range ChangeKinds {
+		if n := d.Count(k); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, k))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no changes")
+	}
+	return fmt.Sprintf("run %d: %s", d.Run.ID, strings.Join(parts, ", "))
+}
+
+func (c *RosterChange) String() string {
+	switch c.Kind {
+	case TeacherChanged, RoomChanged:
+		return fmt.Sprintf("%-18s per %-3s %s (%s): %s -> %s", c.Kind, c.Per, c.Course, c.SyncID, c.Old, c.New)
+	case EnrollmentAdded, EnrollmentRemoved:
+		return fmt.Sprintf("%-18s per %-3s %s (%s) %s: %s %s", c.Kind, c.Per, c.Course, c.SyncID, c.Teacher, c.StudentName, c.PermID)
+	}
+	return fmt.Sprintf("%-18s per %-3s %s (%s) %s", c.Kind, c.Per, c.Course, c.SyncID, c.Teacher)
+}
@@ -0,0 +1,124 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffStu415s(t *testing.T) {
+	advisory := `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,a@aps.edu,101,N
+HS,2021,"Kim, Al",2,M,09,Year,1,YR,100,080000 Advisory,MTWRF,a@aps.edu,101,N
+`
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string // kind, course, student and old>new value of each change
+	}{
+		{name: "unchanged", old: advisory, new: advisory},
+		{
+			name: "enrollments",
+			old:  advisory,
+			new: `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,a@aps.edu,101,N
+HS,2021,"Park, Jo",3,F,10,Year,1,YR,100,080000 Advisory,MTWRF,a@aps.edu,101,N
+`,
+			want: []string{
+				EnrollmentAdded + " 080000 Advisory Park, Jo >",
+				EnrollmentRemoved + " 080000 Advisory Kim, Al >",
+			},
+		},
+		{
+			name: "teacher and room",
+			old:  advisory,
+			new: `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,b@aps.edu,102,N
+HS,2021,"Kim, Al",2,M,09,Year,1,YR,100,080000 Advisory,MTWRF,b@aps.edu,102,N
+`,
+			want: []string{
+				TeacherChanged + " 080000 Advisory  a@aps.edu>b@aps.edu",
+				RoomChanged + " 080000 Advisory  101>102",
+			},
+		},
+		{
+			name: "sections",
+			old:  advisory,
+			new: `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,a@aps.edu,101,N
+HS,2021,"Kim, Al",2,M,09,Year,2,YR,300,120000 Bio,MTWRF,b@aps.edu,103,N
+`,
+			want: []string{
+				SectionAdded + " 120000 Bio  >",
+				EnrollmentAdded + " 120000 Bio Kim, Al >",
+				EnrollmentRemoved + " 080000 Advisory Kim, Al >",
+			},
+		},
+		{
+			name: "same section in two schools",
+			old:  advisory,
+			new: advisory + `
+MS,2021,"Cho, Di",3,F,07,Year,1,YR,100,080000 Advisory,MTWRF,a@aps.edu,201,N
+`,
+			want: []string{
+				SectionAdded + " 080000 Advisory  >",
+				EnrollmentAdded + " 080000 Advisory Cho, Di >",
+			},
+		},
+		{
+			name: "first load",
+			new:  advisory,
+			want: []string{
+				SectionAdded + " 080000 Advisory  >",
+				EnrollmentAdded + " 080000 Advisory Kim, Al >",
+				EnrollmentAdded + " 080000 Advisory Lee, Bo >",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var old, new Stu415s
+			if tt.old != "" {
+				old = readStu415s(t, tt.old)
+			}
+			if tt.new != "" {
+				new = readStu415s(t, tt.new)
+			}
+			var got []string
+			for _, c := range DiffStu415s(old, new) {
+				got = append(got, c.Kind+" "+c.Course+" "+c.StudentName+" "+c.Old+">"+c.New)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortChanges(t *testing.T) {
+	changes := []*RosterChange{
+		{Kind: EnrollmentAdded, Per: "10", Course: "Algebra", StudentName: "b"},
+		{Kind: EnrollmentAdded, Per: "2", Course: "Bio", StudentName: "a"},
+		{Kind: EnrollmentAdded, Per: "2", Course: "Algebra", StudentName: "b"},
+		{Kind: EnrollmentAdded, Per: "2", Course: "Algebra", StudentName: "a"},
+		{Kind: SectionRemoved, Per: "3", Course: "Art"},
+		{Kind: SectionAdded, Per: "4", Course: "Art", School: "MS"},
+		{Kind: SectionAdded, Per: "4", Course: "Art", School: "HS"},
+	}
+	SortChanges(changes)
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Kind+" "+c.Per+" "+c.Course+" "+c.School+" "+c.StudentName)
+	}
+	want := []string{
+		SectionAdded + " 4 Art HS ",
+		SectionAdded + " 4 Art MS ",
+		SectionRemoved + " 3 Art  ",
+		EnrollmentAdded + " 2 Algebra  a",
+		EnrollmentAdded + " 2 Algebra  b",
+		EnrollmentAdded + " 2 Bio  a",
+		EnrollmentAdded + " 10 Algebra  b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted %q, want %q", got, want)
+	}
+}
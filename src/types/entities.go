@@ -0,0 +1,126 @@
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+// PermIDDomain is appended to Perm IDs by Parse to form student emails
+const PermIDDomain = "@aps.edu"
+
+type (
+	// Student is a student and their enrollments, keyed on PermID
+	Student struct {
+		PermID   string  `json:"perm_id,omitempty"`
+		Name     string  `json:"name,omitempty"`
+		Grade    string  `json:"grade,omitempty"`
+		Gender   string  `json:"gender,omitempty"`
+		School   string  `json:"school,omitempty"`
+		Schedule Stu415s `json:"schedule,omitempty"`
+	}
+
+	// Teacher is a staff member and the sections they teach, keyed on Email
+	Teacher struct {
+		Email    string   `json:"email,omitempty"`
+		Name     string   `json:"name,omitempty"`
+		Sections []*Class `json:"sections,omitempty"`
+	}
+)
+
+// PermIDEmail adds PermIDDomain to a bare Perm ID
+func PermIDEmail(permID string) string {
+	if permID == "" || strings.Contains(permID, "@") {
+		return permID
+	}
+	return permID + PermIDDomain
+}
+
+// Stu415sToStudents groups stu415s by PermID into students ordered by name
+// Each schedule is ordered by period
+func Stu415sToStudents(s415s Stu415s) []*Student {
+	byPerm := make(map[string]*Student)
+	students := make([]*Student, 0)
+	for _, s := range s415s {
+		st, ok := byPerm[s.PermID]
+		if !ok {
+			st = &Student{
+				PermID: s.PermID,
+				Name:   s.StudentName,
+				Grade:  s.Grade,
+				Gender: s.Gender,
+				School: s.OrganizationName,
+			}
+			byPerm[s.PermID] = st
+			students = append(students, st)
+		}
+		st.Schedule = append(st.Schedule, s)
+	}
+	for _, st := range students {
+		st.Schedule.SortByPeriod()
+	}
+	sort.SliceStable(students, func(i, j int) bool {
+		if students[i].Name != students[j].Name {
+			return students[i].Name < students[j].Name
+		}
+		return students[i].PermID < students[j].PermID
+	})
+	return students
+}
+
+// Stu415sToTeachers groups stu415s by Teacher into teachers ordered by name
+// Name is the Synergy display name when it was kept in TeacherName
+func Stu415sToTeachers(s415s Stu415s) []*Teacher {
+	byEmail := make(map[string]Stu415s)
+	var order []string
+	for _, s := range s415s {
+		if _, ok := byEmail[s.Teacher]; !ok {
+			order = append(order, s.Teacher)
+		}
+		byEmail[s.Teacher] = append(byEmail[s.Teacher], s)
+	}
+
+	teachers := make([]*Teacher, 0, len(order))
+	for _, email := range order {
+		rows := byEmail[email]
+		name := rows[0].TeacherName
+		if name == "" {
+			name = email
+		}
+		teachers = append(teachers, &Teacher{
+			Email:    email,
+			Name:     name,
+			Sections: Stu415sToClasses(rows),
+		})
+	}
+	sort.SliceStable(teachers, func(i, j int) bool {
+		if teachers[i].Name != teachers[j].Name {
+			return teachers[i].Name < teachers[j].Name
+		}
+		return teachers[i].Email < teachers[j].Email
+	})
+	return teachers
+}
+
+// CourseID returns the code before the title in CourseIDAndTitle
+func (s *Stu415) CourseID() string {
+	id, _ := splitCourse(s.CourseIDAndTitle)
+	return id
+}
+
+// CourseTitle returns the title after the code in CourseIDAndTitle
+func (s *Stu415) CourseTitle() string {
+	_, title := splitCourse(s.CourseIDAndTitle)
+	return title
+}
+
+// splitCourse splits "080000 Advisory" or "080000 - Advisory" into code and title
+func splitCourse(c string) (id, title string) {
+	c = strings.TrimSpace(c)
+	if i := strings.Index(c, " - "); i > 0 {
+		return strings.TrimSpace(c[:i]), strings.TrimSpace(c[i+3:])
+	}
+	if i := strings.IndexAny(c, " \t"); i > 0 {
+		return c[:i], strings.TrimSpace(c[i+1:])
+	}
+	return c, ""
+}
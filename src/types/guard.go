@@ -0,0 +1,206 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AllSchools in Guard.Schools expects every stored school in each update
+const AllSchools = "*"
+
+// Guard holds the safety checks run before a new roster replaces the partitions it covers.
+// A partition new to the roster is checked against its school's latest stored partition
+type Guard struct {
+	// Schools lists the schools every update must cover, or AllSchools. A listed school stored
+	// for the year of an update but missing from it is lost. By default an update covers only
+	// the schools in it, so refreshing one school leaves the others alone
+	Schools []string `json:"schools,omitempty"`
+	// MaxShrinkPercent refuses updates whose row count drops by more than this percent. 0 disables
+	MaxShrinkPercent float64 `json:"max_shrink_percent"`
+	// MaxTeachersLost refuses updates where more teachers lose every class. Negative disables
+	MaxTeachersLost int `json:"max_teachers_lost"`
+	// AllowSchoolLoss accepts updates missing a school of Schools stored for the year they cover
+	AllowSchoolLoss bool `json:"allow_school_loss"`
+	// AllowYearChange accepts updates starting a new school year for a stored school
+	AllowYearChange bool `json:"allow_year_change"`
+	// AllowOldYear accepts updates for a school year older than the school's latest stored year
+	AllowOldYear bool `json:"allow_old_year"`
+}
+
+// DefaultGuard is used when no guard is configured
+var DefaultGuard = Guard{MaxShrinkPercent: 10, MaxTeachersLost: 5}
+
+// GuardReport explains the checks a new roster failed
+type GuardReport struct {
+	OldRows      int         `json:"old_rows"`
+	NewRows      int         `json:"new_rows"`
+	Partitions   []Partition `json:"partitions,omitempty"`
+	LostTeachers []string    `json:"lost_teachers,omitempty"`
+	LostSchools  []Partition `json:"lost_schools,omitempty"`
+	NewYears     []Partition `json:"new_years,omitempty"`
+	OldYears     []Partition `json:"old_years,omitempty"`
+	Violations   []string    `json:"violations,omitempty"`
+}
+
+// GuardError is returned when a roster update is refused by its Guard
+type GuardError struct {
+	Report *GuardReport
+}
+
+func (e *GuardError) Error() string {
+	return "roster update refused (use -force to override): " + strings.Join(e.Report.Violations, "; ")
+}
+
+func distinct(s415s Stu415s, field func(*Stu415) string) map[string]bool {
+	set := make(map[string]bool)
+	for _, s := range s415s {
+		set[field(s)] = true
+	}
+	return set
+}
+
+func missing(old, new map[string]bool) []string {
+	var list []string
+	for k := range old {
+		if !new[k] {
+			list = append(list, k)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Check compares a new roster to the old rows it replaces: the stored rows of its partitions
+// and, for a partition new to the roster, the rows of its school's latest stored partition.
+// stored lists every partition on the roster. The report has no violations if the update is
+// safe or is the first for its schools
+func (g Guard) Check(old, new Stu415s, stored []Partition) *GuardReport {
+	r := &GuardReport{OldRows: len(old), NewRows: len(new), Partitions: new.Partitions()}
+	latest := make(map[string]string)
+	has := make(map[Partition]bool)
+	for _, p := range stored {
+		has[p] = true
+		if p.Year > latest[p.School] {
+			latest[p.School] = p.Year
+		}
+	}
+
+	updated := make(map[Partition]bool)
+	for _, p := range r.Partitions {
+		updated[p] = true
+		switch {
+		case has[p] || latest[p.School] == "":
+		case p.Year < latest[p.School]:
+			r.OldYears = append(r.OldYears, p)
+		default:
+			r.NewYears = append(r.NewYears, p)
+		}
+	}
+	for _, p := range r.Partitions {
+		for _, s := range schoolsIn(stored, p.Year) {
+			lost := Partition{School: s, Year: p.Year}
+			if g.covers(s) && !updated[lost] {
+				updated[lost] = true
+				r.LostSchools = append(r.LostSchools, lost)
+			}
+		}
+	}
+
+	if !g.AllowSchoolLoss && len(r.LostSchools) > 0 {
+		var list []string
+		for _, p := range r.LostSchools {
+			list = append(list, p.String())
+		}
+		r.Violations = append(r.Violations, fmt.Sprintf("schools missing from new roster: %s", strings.Join(list, ", ")))
+	}
+	if !g.AllowYearChange && len(r.NewYears) > 0 {
+		var list []string
+		for _, p := range r.NewYears {
+			list = append(list, fmt.Sprintf("%s from %s to %s", p.School, latest[p.School], p.Year))
+		}
+		r.Violations = append(r.Violations, fmt.Sprintf("school year changed: %s", strings.Join(list, ", ")))
+	}
+	if !g.AllowOldYear && len(r.OldYears) > 0 {
+		var list []string
+		for _, p := range r.OldYears {
+			list = append(list, fmt.Sprintf("%s (latest %s)", p, latest[p.School]))
+		}
+		r.Violations = append(r.Violations, fmt.Sprintf("older school years: %s", strings.Join(list, ", ")))
+	}
+	if len(old) == 0 {
+		return r
+	}
+
+	if g.MaxShrinkPercent > 0 && len(new) < len(old) {
+		shrink := 100 * float64(len(old)-len(new)) / float64(len(old))
+		if shrink > g.MaxShrinkPercent {
+			r.Violations = append(r.Violations, fmt.Sprintf("row count dropped %.1f%% (%d -> %d), more than %.1f%%", shrink, len(old), len(new), g.MaxShrinkPercent))
+		}
+	}
+
+	r.LostTeachers = missing(distinct(old, func(s *Stu415) string { return s.Teacher }), distinct(new, func(s *Stu415) string { return s.Teacher }))
+	if g.MaxTeachersLost >= 0 && len(r.LostTeachers) > g.MaxTeachersLost {
+		r.Violations = append(r.Violations, fmt.Sprintf("%d teachers lost all classes, more than %d", len(r.LostTeachers), g.MaxTeachersLost))
+	}
+	return r
+}
+
+// covers is true if every update must include school
+func (g Guard) covers(school string) bool {
+	for _, s := range g.Schools {
+		if s == AllSchools || s == school {
+			return true
+		}
+	}
+	return false
+}
+
+// schoolsIn returns the schools stored for year or, if none are, for the latest stored year
+// before it
+func schoolsIn(stored []Partition, year string) []string {
+	ref := ""
+	for _, p := range stored {
+		if p.Year == year {
+			ref = year
+			break
+		}
+		if p.Year < year && p.Year > ref {
+			ref = p.Year
+		}
+	}
+	var schools []string
+	for _, p := range stored {
+		if ref != "" && p.Year == ref {
+			schools = append(schools, p.School)
+		}
+	}
+	sort.Strings(schools)
+	return schools
+}
+
+// OK is true if no check failed
+func (r *GuardReport) OK() bool {
+	return len(r.Violations) == 0
+}
+
+func (r *GuardReport) String() string {
+	var b strings.Builder
+	var parts []string
+	for _, p := range r.Partitions {
+		parts = append(parts, p.String())
+	}
+	fmt.Fprintf(&b, "roster guard (%s): %d -> %d rows", strings.Join(parts, ", "), r.OldRows, r.NewRows)
+	if r.OK() {
+		b.WriteString(", all checks passed\n")
+		return b.String()
+	}
+	b.WriteByte('\n')
+	for _, v := range r.Violations {
+		fmt.Fprintf(&b, "  refused: %s\n", v)
+	}
+	if len(r.LostTeachers) > 0 {
+		fmt.Fprintf(&b, "  teachers without classes: %s\n", strings.Join(r.LostTeachers, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// guardRoster returns n rows for school and year spread over teachers t0..t(teachers-1)
+func guardRoster(school, year string, n, teachers int) Stu415s {
+	s415s := make(Stu415s, n)
+	for i := range s415s {
+		s415s[i] = &Stu415{
+			OrganizationName: school,
+			SchoolYear:       year,
+			PermID:           fmt.Sprint(i),
+			Teacher:          fmt.Sprintf("t%d@aps.edu", i%teachers),
+		}
+	}
+	return s415s
+}
+
+func TestGuardCheck(t *testing.T) {
+	hs := Partition{School: "HS", Year: "2021"}
+	ms := Partition{School: "MS", Year: "2021"}
+	tests := []struct {
+		name     string
+		guard    Guard
+		old, new Stu415s
+		stored   []Partition
+		refused  []string
+	}{
+		{
+			name:  "first load",
+			guard: DefaultGuard,
+			new:   guardRoster("HS", "2021", 10, 2),
+		},
+		{
+			name:   "same roster",
+			guard:  DefaultGuard,
+			old:    guardRoster("HS", "2021", 100, 10),
+			new:    guardRoster("HS", "2021", 100, 10),
+			stored: []Partition{hs},
+		},
+		{
+			name:    "shrink",
+			guard:   DefaultGuard,
+			old:     guardRoster("HS", "2021", 100, 10),
+			new:     guardRoster("HS", "2021", 80, 10),
+			stored:  []Partition{hs},
+			refused: []string{"row count dropped 20.0%"},
+		},
+		{
+			name:   "shrink disabled",
+			guard:  Guard{MaxTeachersLost: -1},
+			old:    guardRoster("HS", "2021", 100, 10),
+			new:    guardRoster("HS", "2021", 10, 1),
+			stored: []Partition{hs},
+		},
+		{
+			name:    "teachers lost",
+			guard:   Guard{MaxTeachersLost: 2},
+			old:     guardRoster("HS", "2021", 100, 10),
+			new:     guardRoster("HS", "2021", 100, 7),
+			stored:  []Partition{hs},
+			refused: []string{"3 teachers lost all classes"},
+		},
+		{
+			name:    "truncated new year",
+			guard:   Guard{MaxShrinkPercent: 10, MaxTeachersLost: 5, AllowYearChange: true},
+			old:     guardRoster("HS", "2021", 100, 10),
+			new:     guardRoster("HS", "2022", 20, 2),
+			stored:  []Partition{hs},
+			refused: []string{"row count dropped 80.0%", "8 teachers lost all classes"},
+		},
+		{
+			name:    "year change",
+			guard:   DefaultGuard,
+			old:     guardRoster("HS", "2021", 100, 10),
+			new:     guardRoster("HS", "2022", 100, 10),
+			stored:  []Partition{hs},
+			refused: []string{"school year changed: HS from 2021 to 2022"},
+		},
+		{
+			name:   "one school refreshed",
+			guard:  DefaultGuard,
+			old:    guardRoster("HS", "2021", 100, 10),
+			new:    guardRoster("HS", "2021", 100, 10),
+			stored: []Partition{hs, ms},
+		},
+		{
+			name:    "lost school",
+			guard:   Guard{Schools: []string{AllSchools}, MaxTeachersLost: -1},
+			old:     guardRoster("HS", "2021", 100, 10),
+			new:     guardRoster("HS", "2021", 100, 10),
+			stored:  []Partition{hs, ms},
+			refused: []string{"schools missing from new roster: MS 2021"},
+		},
+		{
+			name:    "lost listed school",
+			guard:   Guard{Schools: []string{"MS"}, MaxTeachersLost: -1},
+			old:     guardRoster("HS", "2021", 100, 10),
+			new:     guardRoster("HS", "2021", 100, 10),
+			stored:  []Partition{hs, ms, {School: "ES", Year: "2021"}},
+			refused: []string{"schools missing from new roster: MS 2021"},
+		},
+		{
+			name:    "lost school in new year",
+			guard:   Guard{Schools: []string{AllSchools}, AllowYearChange: true, MaxTeachersLost: -1},
+			old:     guardRoster("HS", "2021", 100, 10),
+			new:     guardRoster("HS", "2022", 100, 10),
+			stored:  []Partition{hs, ms},
+			refused: []string{"schools missing from new roster: MS 2022"},
+		},
+		{
+			name:   "school loss allowed",
+			guard:  Guard{Schools: []string{AllSchools}, AllowSchoolLoss: true, MaxTeachersLost: -1},
+			old:    guardRoster("HS", "2021", 100, 10),
+			new:    guardRoster("HS", "2021", 100, 10),
+			stored: []Partition{hs, ms},
+		},
+		{
+			name:    "old year",
+			guard:   DefaultGuard,
+			new:     guardRoster("HS", "2020", 10, 2),
+			stored:  []Partition{hs},
+			refused: []string{"older school years: HS 2020 (latest 2021)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.guard.Check(tt.old, tt.new, tt.stored)
+			if len(r.Violations) != len(tt.refused) {
+				t.Fatalf("violations %q, want %q", r.Violations, tt.refused)
+			}
+			for i, v := range r.Violations {
+				if !strings.HasPrefix(v, tt.refused[i]) {
+					t.Errorf("violation %d = %q, want prefix %q", i, v, tt.refused[i])
+				}
+			}
+			if r.OK() != (len(tt.refused) == 0) {
+				t.Errorf("OK() = %v", r.OK())
+			}
+		})
+	}
+}
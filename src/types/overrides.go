@@ -0,0 +1,198 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Override kinds
+const (
+	OverrideAdd    = "add"
+	OverrideRemove = "remove"
+	OverrideSwap   = "swap"
+)
+
+type (
+	// Override is a local roster change kept across refreshes until it expires or is deleted.
+	// add puts PermID in section SyncID, remove hides PermID from it and swap gives it Teacher.
+	// The section is looked up in School and Year; an empty School or Year matches any
+	Override struct {
+		ID          int64     `json:"id"`
+		Kind        string    `json:"kind"`
+		School      string    `json:"school,omitempty"`
+		Year        string    `json:"year,omitempty"`
+		SyncID      string    `json:"sync_id"`
+		PermID      string    `json:"perm_id,omitempty"`
+		StudentName string    `json:"student_name,omitempty"`
+		Teacher     string    `json:"teacher,omitempty"`
+		Author      string    `json:"author"`
+		Reason      string    `json:"reason"`
+		Created     time.Time `json:"created"`
+		// Expires is zero for overrides that never expire
+		Expires time.Time `json:"expires,omitempty"`
+		// Redundant is when a refresh first made the override unnecessary, or zero
+		Redundant time.Time `json:"redundant,omitempty"`
+	}
+
+	// Overrides are applied in ID order
+	Overrides []*Override
+
+	// OverrideReport sorts overrides by what they did to a refreshed roster
+	OverrideReport struct {
+		Applied []*Override `json:"applied,omitempty"`
+		// Redundant overrides matched rows Synergy already had
+		Redundant []*Override `json:"redundant,omitempty"`
+		// Unmatched overrides name a section or student that is not on the roster
+		Unmatched []*Override `json:"unmatched,omitempty"`
+		Expired   []*Override `json:"expired,omitempty"`
+	}
+)
+
+// Validate returns an error if o is missing the fields its kind needs
+func (o *Override) Validate() error {
+	if o.SyncID == "" {
+		return fmt.Errorf("override %s: missing section sync id", o.Kind)
+	}
+	switch o.Kind {
+	case OverrideAdd, OverrideRemove:
+		if o.PermID == "" {
+			return fmt.Errorf("override %s: missing perm id", o.Kind)
+		}
+	case OverrideSwap:
+		if o.Teacher == "" {
+			return fmt.Errorf("override swap: missing teacher")
+		}
+	default:
+		return fmt.Errorf("unknown override kind %q", o.Kind)
+	}
+	if o.Author == "" || o.Reason == "" {
+		return fmt.Errorf("override %s: author and reason are required", o.Kind)
+	}
+	return nil
+}
+
+// Active is true if o has not expired at t
+func (o *Override) Active(t time.Time) bool {
+	return o.Expires.IsZero() || t.Before(o.Expires)
+}
+
+func (o *Override) String() string {
+	var target string
+	switch o.Kind {
+	case OverrideSwap:
+		target = "teacher " + o.Teacher
+	default:
+		target = "student " + o.PermID
+	}
+	section := o.SyncID
+	if p := strings.TrimSpace(o.School + " " + o.Year); p != "" {
+		section += " (" + p + ")"
+	}
+	s := fmt.Sprintf("#%d %s %s in %s by %s: %s", o.ID, o.Kind, target, section, o.Author, o.Reason)
+	if !o.Expires.IsZero() {
+		s += " (expires " + o.Expires.Local().Format("2006-01-02") + ")"
+	}
+	return s
+}
+
+// Apply changes s415s by every override active at t. Overrides are checked against
+// the rows Synergy sent, so one that changes nothing is reported as redundant
+func (ovs Overrides) Apply(s415s Stu415s, t time.Time) (Stu415s, *OverrideReport) {
+	report := new(OverrideReport)
+	for _, o := range ovs {
+		if !o.Active(t) {
+			report.Expired = append(report.Expired, o)
+			continue
+		}
+		var status int
+		s415s, status = o.apply(s415s)
+		switch status {
+		case overrideApplied:
+			report.Applied = append(report.Applied, o)
+		case overrideRedundant:
+			report.Redundant = append(report.Redundant, o)
+		default:
+			report.Unmatched = append(report.Unmatched, o)
+		}
+	}
+	return s415s, report
+}
+
+const (
+	overrideUnmatched = iota
+	overrideApplied
+	overrideRedundant
+)
+
+// inSection is true if s is a row of the override's section
+func (o *Override) inSection(s *Stu415) bool {
+	return s.SyncID == o.SyncID && (o.School == "" || s.OrganizationName == o.School) && (o.Year == "" || s.SchoolYear == o.Year)
+}
+
+func (o *Override) apply(s415s Stu415s) (Stu415s, int) {
+	var section, student *Stu415
+	enrolled := false
+	for _, s := range s415s {
+		if o.inSection(s) {
+			section = s
+			if s.PermID == o.PermID {
+				enrolled = true
+			}
+		}
+		if student == nil && o.PermID != "" && s.PermID == o.PermID {
+			student = s
+		}
+	}
+	if section == nil {
+		return s415s, overrideUnmatched
+	}
+
+	switch o.Kind {
+	case OverrideAdd:
+		if enrolled {
+			return s415s, overrideRedundant
+		}
+		s := *section
+		s.PermID, s.StudentName, s.Gender, s.Grade, s.Prescheduled = o.PermID, o.StudentName, "", "", ""
+		if student != nil {
+			s.StudentName, s.Gender, s.Grade = student.StudentName, student.Gender, student.Grade
+		}
+		return append(s415s, &s), overrideApplied
+	case OverrideRemove:
+		if !enrolled {
+			return s415s, overrideRedundant
+		}
+		out := s415s[:0]
+		for _, s := range s415s {
+			if !o.inSection(s) || s.PermID != o.PermID {
+				out = append(out, s)
+			}
+		}
+		return out, overrideApplied
+	case OverrideSwap:
+		teacher := strings.ToLower(o.Teacher)
+		if section.Teacher == teacher {
+			return s415s, overrideRedundant
+		}
+		for _, s := range s415s {
+			if o.inSection(s) {
+				s.Teacher, s.TeacherName = teacher, ""
+			}
+		}
+		return s415s, overrideApplied
+	}
+	return s415s, overrideUnmatched
+}
+
+func (r *OverrideReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "overrides: %d applied, %d redundant, %d unmatched, %d expired\n", len(r.Applied), len(r.Redundant), len(r.Unmatched), len(r.Expired))
+	for _, o := range r.Redundant {
+		fmt.Fprintf(&b, "  redundant %s\n", o)
+	}
+	for _, o := range r.Unmatched {
+		fmt.Fprintf(&b, "  unmatched %s\n", o)
+	}
+	return b.String()
+}
@@ -0,0 +1,220 @@
+package types
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// overridesRoster has Lee and Kim in Algebra and Cho in Bio
+const overridesRoster = `
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,a@aps.edu,102,N
+HS,2021,"Kim, Al",2,M,10,Year,2,YR,200,110000 Algebra,MTWRF,a@aps.edu,102,N
+HS,2021,"Cho, Di",3,F,11,Year,3,YR,300,120000 Bio,MTWRF,b@aps.edu,103,N
+`
+
+func TestOverridesApply(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	unchanged := []string{
+		"110000 Algebra Kim, Al 10 a@aps.edu",
+		"110000 Algebra Lee, Bo 09 a@aps.edu",
+		"120000 Bio Cho, Di 11 b@aps.edu",
+	}
+	tests := []struct {
+		name    string
+		section string // course whose sync id the override names
+		o       Override
+		status  string
+		rows    []string // course, student, grade and teacher of each row, sorted
+	}{
+		{
+			name:    "add a rostered student",
+			section: "110000 Algebra",
+			o:       Override{Kind: OverrideAdd, PermID: PermIDEmail("3"), StudentName: "ignored"},
+			status:  "applied",
+			rows: []string{
+				"110000 Algebra Cho, Di 11 a@aps.edu",
+				"110000 Algebra Kim, Al 10 a@aps.edu",
+				"110000 Algebra Lee, Bo 09 a@aps.edu",
+				"120000 Bio Cho, Di 11 b@aps.edu",
+			},
+		},
+		{
+			name:    "add a new student",
+			section: "120000 Bio",
+			o:       Override{Kind: OverrideAdd, PermID: PermIDEmail("4"), StudentName: "Park, Jo"},
+			status:  "applied",
+			rows:    append(unchanged[:3:3], "120000 Bio Park, Jo  b@aps.edu"),
+		},
+		{
+			name:    "add an enrolled student",
+			section: "110000 Algebra",
+			o:       Override{Kind: OverrideAdd, PermID: PermIDEmail("1")},
+			status:  "redundant",
+		},
+		{
+			name:    "add to a missing section",
+			section: "130000 Art",
+			o:       Override{Kind: OverrideAdd, PermID: PermIDEmail("1")},
+			status:  "unmatched",
+		},
+		{
+			name:    "remove",
+			section: "110000 Algebra",
+			o:       Override{Kind: OverrideRemove, PermID: PermIDEmail("2")},
+			status:  "applied",
+			rows:    []string{"110000 Algebra Lee, Bo 09 a@aps.edu", "120000 Bio Cho, Di 11 b@aps.edu"},
+		},
+		{
+			name:    "remove an unenrolled student",
+			section: "110000 Algebra",
+			o:       Override{Kind: OverrideRemove, PermID: PermIDEmail("3")},
+			status:  "redundant",
+		},
+		{
+			name:    "swap",
+			section: "110000 Algebra",
+			o:       Override{Kind: OverrideSwap, Teacher: "C@aps.edu"},
+			status:  "applied",
+			rows:    []string{"110000 Algebra Kim, Al 10 c@aps.edu", "110000 Algebra Lee, Bo 09 c@aps.edu", "120000 Bio Cho, Di 11 b@aps.edu"},
+		},
+		{
+			name:    "swap to the current teacher",
+			section: "120000 Bio",
+			o:       Override{Kind: OverrideSwap, Teacher: "B@aps.edu"},
+			status:  "redundant",
+		},
+		{
+			name:    "expired",
+			section: "110000 Algebra",
+			o:       Override{Kind: OverrideRemove, PermID: PermIDEmail("2"), Expires: now},
+			status:  "expired",
+		},
+		{
+			name:    "expires later",
+			section: "110000 Algebra",
+			o:       Override{Kind: OverrideRemove, PermID: PermIDEmail("2"), Expires: now.Add(time.Second)},
+			status:  "applied",
+			rows:    []string{"110000 Algebra Lee, Bo 09 a@aps.edu", "120000 Bio Cho, Di 11 b@aps.edu"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s415s := readStu415s(t, overridesRoster)
+			o := tt.o
+			o.SyncID = "missing"
+			for _, s := range s415s {
+				if s.CourseIDAndTitle == tt.section {
+					o.SyncID = s.SyncID
+				}
+			}
+
+			s415s, r := Overrides{&o}.Apply(s415s, now)
+			buckets := map[string][]*Override{"applied": r.Applied, "redundant": r.Redundant, "unmatched": r.Unmatched, "expired": r.Expired}
+			for status, got := range buckets {
+				if want := status == tt.status; want != (len(got) == 1) {
+					t.Errorf("%s %v, want the override %v", status, got, want)
+				}
+			}
+			var rows []string
+			for _, s := range s415s {
+				rows = append(rows, s.CourseIDAndTitle+" "+s.StudentName+" "+s.Grade+" "+s.Teacher)
+			}
+			sort.Strings(rows)
+			want := tt.rows
+			if want == nil {
+				want = unchanged
+			}
+			if !reflect.DeepEqual(rows, want) {
+				t.Errorf("rows %q, want %q", rows, want)
+			}
+		})
+	}
+}
+
+func TestOverridesApplyAcrossYears(t *testing.T) {
+	// the default sync id scheme gives Algebra the same id in both years
+	roster := `
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,a@aps.edu,102,N
+HS,2022,"Kim, Al",2,M,10,Year,2,YR,200,110000 Algebra,MTWRF,a@aps.edu,102,N
+`
+	tests := []struct {
+		name   string
+		o      Override
+		status string
+		rows   []string // year, student and teacher of each row, sorted
+	}{
+		{
+			name:   "remove from another year",
+			o:      Override{Kind: OverrideRemove, School: "HS", Year: "2022", PermID: PermIDEmail("1")},
+			status: "redundant",
+			rows:   []string{"2021 Lee, Bo a@aps.edu", "2022 Kim, Al a@aps.edu"},
+		},
+		{
+			name:   "add in one year",
+			o:      Override{Kind: OverrideAdd, School: "HS", Year: "2022", PermID: PermIDEmail("1")},
+			status: "applied",
+			rows:   []string{"2021 Lee, Bo a@aps.edu", "2022 Kim, Al a@aps.edu", "2022 Lee, Bo a@aps.edu"},
+		},
+		{
+			name:   "swap in one year",
+			o:      Override{Kind: OverrideSwap, School: "HS", Year: "2022", Teacher: "c@aps.edu"},
+			status: "applied",
+			rows:   []string{"2021 Lee, Bo a@aps.edu", "2022 Kim, Al c@aps.edu"},
+		},
+		{
+			name:   "swap in another school",
+			o:      Override{Kind: OverrideSwap, School: "MS", Year: "2022", Teacher: "c@aps.edu"},
+			status: "unmatched",
+			rows:   []string{"2021 Lee, Bo a@aps.edu", "2022 Kim, Al a@aps.edu"},
+		},
+		{
+			name:   "swap in every year",
+			o:      Override{Kind: OverrideSwap, Teacher: "c@aps.edu"},
+			status: "applied",
+			rows:   []string{"2021 Lee, Bo c@aps.edu", "2022 Kim, Al c@aps.edu"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s415s := readStu415s(t, roster)
+			o := tt.o
+			o.SyncID = s415s[0].SyncID
+
+			s415s, r := Overrides{&o}.Apply(s415s, time.Now())
+			buckets := map[string][]*Override{"applied": r.Applied, "redundant": r.Redundant, "unmatched": r.Unmatched}
+			if got := buckets[tt.status]; len(got) != 1 {
+				t.Errorf("%s %v, want the override", tt.status, got)
+			}
+			var rows []string
+			for _, s := range s415s {
+				rows = append(rows, s.SchoolYear+" "+s.StudentName+" "+s.Teacher)
+			}
+			sort.Strings(rows)
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows %q, want %q", rows, tt.rows)
+			}
+		})
+	}
+}
+
+func TestOverrideValidate(t *testing.T) {
+	tests := []struct {
+		o  Override
+		ok bool
+	}{
+		{Override{Kind: OverrideAdd, SyncID: "v2-1", PermID: "1", Author: "me", Reason: "why"}, true},
+		{Override{Kind: OverrideSwap, SyncID: "v2-1", Teacher: "c@aps.edu", Author: "me", Reason: "why"}, true},
+		{Override{Kind: OverrideAdd, PermID: "1", Author: "me", Reason: "why"}, false},
+		{Override{Kind: OverrideRemove, SyncID: "v2-1", Author: "me", Reason: "why"}, false},
+		{Override{Kind: OverrideSwap, SyncID: "v2-1", Author: "me", Reason: "why"}, false},
+		{Override{Kind: OverrideAdd, SyncID: "v2-1", PermID: "1", Author: "me"}, false},
+		{Override{Kind: "move", SyncID: "v2-1", Author: "me", Reason: "why"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.o.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v", tt.o, err)
+		}
+	}
+}
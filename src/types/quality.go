@@ -0,0 +1,267 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Data quality finding kinds
+const (
+	ScheduleConflict = "schedule_conflict"
+	NoTeacherEmail   = "no_teacher_email"
+	MissingRoom      = "missing_room"
+	DuplicateRow     = "duplicate_row"
+	TermGap          = "term_gap"
+	EmptySection     = "empty_section"
+)
+
+// FindingKinds lists finding kinds in report order
+var FindingKinds = []string{ScheduleConflict, NoTeacherEmail, MissingRoom, DuplicateRow, TermGap, EmptySection}
+
+type (
+	// Finding is one data quality problem in a download. Student fields are empty for
+	// section problems and section fields for term gaps
+	Finding struct {
+		Kind        string `json:"kind"`
+		School      string `json:"school,omitempty"`
+		Year        string `json:"year,omitempty"`
+		SyncID      string `json:"sync_id,omitempty"`
+		Course      string `json:"course,omitempty"`
+		Per         string `json:"per,omitempty"`
+		Term        string `json:"term,omitempty"`
+		Teacher     string `json:"teacher,omitempty"`
+		PermID      string `json:"perm_id,omitempty"`
+		StudentName string `json:"student_name,omitempty"`
+		Detail      string `json:"detail,omitempty"`
+	}
+
+	// CheckRun is one data quality pass over a download
+	CheckRun struct {
+		ID   int64     `json:"id"`
+		At   time.Time `json:"at"`
+		Rows int       `json:"rows"`
+	}
+
+	// CheckReport holds the findings of a check run
+	CheckReport struct {
+		Run      CheckRun   `json:"run"`
+		Findings []*Finding `json:"findings,omitempty"`
+	}
+)
+
+// CheckQuality looks for problems in a download whose teachers have been matched to emails:
+// students in two sections meeting the same period in overlapping terms and days, sections
+// whose teacher has no email or that have no room, repeated rows, students without classes
+// in a quarter their school and year schedules, and sections without students. Run it before
+// CheckRows, which drops the blank student rows Synergy reports for empty sections
+func CheckQuality(s415s Stu415s) []*Finding {
+	var findings []*Finding
+	type section struct {
+		first    *Stu415
+		students int
+	}
+	sections := make(map[string]*section)
+	var sectionOrder []string
+	rows := make(map[string]int)
+	periods := make(map[string][]*Stu415)
+	var periodOrder []string
+	quarters := make(map[Partition]map[TermCode]bool)
+	studentQuarters := make(map[Partition]map[string]map[TermCode]bool)
+	students := make(map[Partition]map[string]*Stu415)
+
+	for _, s := range s415s {
+		k := sectionKey(s)
+		sec, ok := sections[k]
+		if !ok {
+			sec = &section{first: s}
+			sections[k] = sec
+			sectionOrder = append(sectionOrder, k)
+		}
+		if !hasStudent(s) {
+			continue
+		}
+		sec.students++
+
+		rk := k + syncIDSep + s.PermID
+		if rows[rk]++; rows[rk] == 2 {
+			f := sectionFinding(DuplicateRow, s)
+			f.PermID, f.StudentName = s.PermID, s.StudentName
+			findings = append(findings, f)
+		}
+
+		if s.Per != "" {
+			pk := strings.Join([]string{s.OrganizationName, s.SchoolYear, s.PermID, s.Per}, syncIDSep)
+			if _, ok := periods[pk]; !ok {
+				periodOrder = append(periodOrder, pk)
+			}
+			periods[pk] = append(periods[pk], s)
+		}
+
+		// students are checked only for the quarters of recognized terms
+		qs := s.TermCode().Quarters()
+		if len(qs) == 0 {
+			continue
+		}
+		p := Partition{School: s.OrganizationName, Year: s.SchoolYear}
+		if quarters[p] == nil {
+			quarters[p] = make(map[TermCode]bool)
+			studentQuarters[p] = make(map[string]map[TermCode]bool)
+			students[p] = make(map[string]*Stu415)
+		}
+		if studentQuarters[p][s.PermID] == nil {
+			studentQuarters[p][s.PermID] = make(map[TermCode]bool)
+			students[p][s.PermID] = s
+		}
+		for _, q := range qs {
+			quarters[p][q] = true
+			studentQuarters[p][s.PermID][q] = true
+		}
+	}
+
+	for _, k := range sectionOrder {
+		s := sections[k].first
+		if sections[k].students == 0 {
+			findings = append(findings, sectionFinding(EmptySection, s))
+		}
+		if !strings.Contains(s.Teacher, "@") {
+			f := sectionFinding(NoTeacherEmail, s)
+			f.Detail = fmt.Sprintf("no email for %q", s.Teacher)
+			findings = append(findings, f)
+		}
+		if strings.TrimSpace(s.Room) == "" {
+			findings = append(findings, sectionFinding(MissingRoom, s))
+		}
+	}
+
+	for _, pk := range periodOrder {
+		list := periods[pk]
+		for i := 0; i < len(list); i++ {
+			for j := i + 1; j < len(list); j++ {
+				a, b := list[i], list[j]
+				if sectionKey(a) == sectionKey(b) || !termsOverlap(a, b) || !a.Days().Overlaps(b.Days()) {
+					continue
+				}
+				f := sectionFinding(ScheduleConflict, a)
+				f.PermID, f.StudentName = a.PermID, a.StudentName
+				f.Detail = fmt.Sprintf("also in %s (%s) with %s", b.CourseIDAndTitle, b.SyncID, b.Teacher)
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	for p, all := range quarters {
+		for perm, has := range studentQuarters[p] {
+			var missing []string
+			for _, q := range FullYear.Quarters() {
+				if all[q] && !has[q] {
+					missing = append(missing, q.String())
+				}
+			}
+			if len(missing) == 0 {
+				continue
+			}
+			s := students[p][perm]
+			findings = append(findings, &Finding{
+				Kind:        TermGap,
+				School:      p.School,
+				Year:        p.Year,
+				Term:        strings.Join(missing, " "),
+				PermID:      s.PermID,
+				StudentName: s.StudentName,
+				Detail:      "no enrollments in " + strings.Join(missing, ", "),
+			})
+		}
+	}
+
+	SortFindings(findings)
+	return findings
+}
+
+func sectionFinding(kind string, s *Stu415) *Finding {
+	return &Finding{
+		Kind:    kind,
+		School:  s.OrganizationName,
+		Year:    s.SchoolYear,
+		SyncID:  s.SyncID,
+		Course:  s.CourseIDAndTitle,
+		Per:     s.Per,
+		Term:    s.Term,
+		Teacher: s.Teacher,
+	}
+}
+
+// hasStudent is false for the blank student rows of empty sections, whose perm id is
+// empty or, once parsed, only PermIDDomain
+func hasStudent(s *Stu415) bool {
+	return strings.TrimSuffix(strings.TrimSpace(s.PermID), PermIDDomain) != ""
+}
+
+// termsOverlap is true if a and b share a quarter. Unrecognized terms overlap only the same term
+func termsOverlap(a, b *Stu415) bool {
+	ta, tb := a.TermCode(), b.TermCode()
+	if ta == TermUnknown || tb == TermUnknown {
+		return a.Term == b.Term
+	}
+	return ta.Overlaps(tb)
+}
+
+// SortFindings orders findings by kind, then school, period, course and student
+func SortFindings(findings []*Finding) {
+	order := make(map[string]int, len(FindingKinds))
+	for i, k := range FindingKinds {
+		order[k] = i
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.School != b.School {
+			return a.School < b.School
+		}
+		if pa, pb := ParsePeriod(a.Per), ParsePeriod(b.Per); pa != pb {
+			return pa.Less(pb)
+		}
+		if a.Course != b.Course {
+			return a.Course < b.Course
+		}
+		return a.StudentName < b.StudentName
+	})
+}
+
+// Count returns the number of findings of kind
+func (r *CheckReport) Count(kind string) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary counts the findings of each kind
+func (r *CheckReport) Summary() string {
+	var parts []string
+	for _, k := range FindingKinds {
+		if n := r.Count(k); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, k))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no findings")
+	}
+	return fmt.Sprintf("check %d: %s", r.Run.ID, strings.Join(parts, ", "))
+}
+
+func (f *Finding) String() string {
+	switch f.Kind {
+	case TermGap:
+		return fmt.Sprintf("%-17s %s %s: %s", f.Kind, f.StudentName, f.PermID, f.Detail)
+	case ScheduleConflict, DuplicateRow:
+		return strings.TrimSpace(fmt.Sprintf("%-17s per %-3s %s (%s) %s: %s %s %s", f.Kind, f.Per, f.Course, f.SyncID, f.Teacher, f.StudentName, f.PermID, f.Detail))
+	}
+	return strings.TrimSpace(fmt.Sprintf("%-17s per %-3s %s (%s) %s %s", f.Kind, f.Per, f.Course, f.SyncID, f.Teacher, f.Detail))
+}
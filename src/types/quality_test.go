@@ -0,0 +1,127 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+// qualityRow returns an enrollment of perm in a section of teacher
+func qualityRow(perm, per, term, days, course, teacher, room string) *Stu415 {
+	return &Stu415{
+		OrganizationName: "HS",
+		SchoolYear:       "2021",
+		StudentName:      "Student " + perm,
+		PermID:           perm,
+		Per:              per,
+		Term:             term,
+		MeetDays:         days,
+		CourseIDAndTitle: course,
+		SectionID:        course,
+		SyncID:           course,
+		Teacher:          teacher,
+		Room:             room,
+	}
+}
+
+func TestCheckQuality(t *testing.T) {
+	tests := []struct {
+		name  string
+		s415s Stu415s
+		want  []string // kind and detail of each finding
+	}{
+		{
+			name: "clean",
+			s415s: Stu415s{
+				qualityRow("1", "1", "YR", "MTWRF", "Algebra", "a@aps.edu", "101"),
+				qualityRow("1", "2", "YR", "MTWRF", "Bio", "b@aps.edu", "102"),
+			},
+		},
+		{
+			name: "year and semester conflict",
+			s415s: Stu415s{
+				qualityRow("1", "1", "YR", "MTWRF", "Algebra", "a@aps.edu", "101"),
+				qualityRow("1", "1", "S1", "MTWRF", "Art", "b@aps.edu", "102"),
+			},
+			want: []string{ScheduleConflict + " also in Art (Art) with b@aps.edu"},
+		},
+		{
+			name: "semesters do not conflict",
+			s415s: Stu415s{
+				qualityRow("1", "1", "S1", "MTWRF", "Algebra", "a@aps.edu", "101"),
+				qualityRow("1", "1", "S2", "MTWRF", "Art", "b@aps.edu", "102"),
+			},
+		},
+		{
+			name: "a and b days do not conflict",
+			s415s: Stu415s{
+				qualityRow("1", "1", "YR", "A", "Algebra", "a@aps.edu", "101"),
+				qualityRow("1", "1", "YR", "B", "Art", "b@aps.edu", "102"),
+			},
+		},
+		{
+			name: "shared days conflict",
+			s415s: Stu415s{
+				qualityRow("1", "1", "YR", "MWF", "Algebra", "a@aps.edu", "101"),
+				qualityRow("1", "1", "YR", "M-F", "Art", "b@aps.edu", "102"),
+				qualityRow("1", "1", "YR", "TR", "Band", "c@aps.edu", "103"),
+			},
+			want: []string{
+				ScheduleConflict + " also in Art (Art) with b@aps.edu",
+				ScheduleConflict + " also in Band (Band) with c@aps.edu",
+			},
+		},
+		{
+			name: "section problems",
+			s415s: Stu415s{
+				qualityRow("1", "1", "YR", "MTWRF", "Algebra", "Garcia, Ana", "101"),
+				qualityRow("1", "1", "YR", "MTWRF", "Algebra", "Garcia, Ana", "101"),
+				qualityRow("1", "2", "YR", "MTWRF", "Bio", "b@aps.edu", " "),
+				qualityRow("", "3", "YR", "MTWRF", "Chem", "c@aps.edu", "103"),
+			},
+			want: []string{
+				NoTeacherEmail + ` no email for "Garcia, Ana"`,
+				MissingRoom + " ",
+				DuplicateRow + " ",
+				EmptySection + " ",
+			},
+		},
+		{
+			name: "semester student has a gap",
+			s415s: Stu415s{
+				qualityRow("1", "1", "S1", "MTWRF", "Algebra", "a@aps.edu", "101"),
+				qualityRow("2", "1", "S1", "MTWRF", "Algebra", "a@aps.edu", "101"),
+				qualityRow("2", "2", "S2", "MTWRF", "Bio", "b@aps.edu", "102"),
+			},
+			want: []string{TermGap + " no enrollments in Q3, Q4"},
+		},
+		{
+			name: "year and semester students are covered",
+			s415s: Stu415s{
+				qualityRow("1", "1", "YR", "MTWRF", "Algebra", "a@aps.edu", "101"),
+				qualityRow("2", "1", "S1", "MTWRF", "Art", "b@aps.edu", "102"),
+				qualityRow("2", "2", "S2", "MTWRF", "Bio", "c@aps.edu", "103"),
+				qualityRow("3", "3", "Q1", "MTWRF", "Chem", "c@aps.edu", "103"),
+				qualityRow("3", "3", "Q2", "MTWRF", "Chem 2", "c@aps.edu", "103"),
+				qualityRow("3", "4", "Semester 2", "MTWRF", "Drama", "d@aps.edu", "104"),
+			},
+		},
+		{
+			name: "unknown terms are not checked for gaps",
+			s415s: Stu415s{
+				qualityRow("1", "1", "YR", "MTWRF", "Algebra", "a@aps.edu", "101"),
+				qualityRow("2", "1", "T1", "MTWRF", "Art", "b@aps.edu", "102"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range CheckQuality(tt.s415s) {
+				got = append(got, f.Kind+" "+f.Detail)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
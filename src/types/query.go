@@ -0,0 +1,119 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stu415Fields are the json names of the Stu415 fields in report column order
+var Stu415Fields = []string{
+	"organization_name", "school_year", "student_name", "perm_id", "gender", "grade", "term_name", "per", "term",
+	"section_id", "course_id_and_title", "meet_days", "teacher", "teacher_name", "room", "prescheduled", "sync_id",
+}
+
+// Field returns a pointer to the Stu415 field with json name, or nil if there is none
+func (s *Stu415) Field(name string) *string {
+	switch name {
+	case "organization_name":
+		return &s.OrganizationName
+	case "school_year":
+		return &s.SchoolYear
+	case "student_name":
+		return &s.StudentName
+	case "perm_id":
+		return &s.PermID
+	case "gender":
+		return &s.Gender
+	case "grade":
+		return &s.Grade
+	case "term_name":
+		return &s.TermName
+	case "per":
+		return &s.Per
+	case "term":
+		return &s.Term
+	case "section_id":
+		return &s.SectionID
+	case "course_id_and_title":
+		return &s.CourseIDAndTitle
+	case "meet_days":
+		return &s.MeetDays
+	case "teacher":
+		return &s.Teacher
+	case "teacher_name":
+		return &s.TeacherName
+	case "room":
+		return &s.Room
+	case "prescheduled":
+		return &s.Prescheduled
+	case "sync_id":
+		return &s.SyncID
+	}
+	return nil
+}
+
+// Query filters, orders and pages roster rows. Empty filters match every row and School
+// and Year follow Scope. Term matches a term or term name, Course a course code or full
+// course title, and Student a perm id or part of a student name
+type Query struct {
+	Scope
+	Term      string `json:"term,omitempty"`
+	Per       string `json:"per,omitempty"`
+	Teacher   string `json:"teacher,omitempty"`
+	Course    string `json:"course,omitempty"`
+	SectionID string `json:"section_id,omitempty"`
+	Room      string `json:"room,omitempty"`
+	Grade     string `json:"grade,omitempty"`
+	Gender    string `json:"gender,omitempty"`
+	Student   string `json:"student,omitempty"`
+
+	// Sort lists Stu415 field names, prefixed with - to sort descending. Rows are then
+	// ordered by period, course and student name
+	Sort []string `json:"sort,omitempty"`
+	// Limit is the most rows returned, or every row if 0; Offset rows are skipped first
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
+	// Fields are the Stu415 fields filled in; every field if empty
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Validate returns an error for unknown sort or projection fields and negative paging
+func (q *Query) Validate() error {
+	for _, s := range q.Sort {
+		if !isStu415Field(strings.TrimPrefix(s, "-")) {
+			return fmt.Errorf("query: can't sort by unknown field %q", s)
+		}
+	}
+	for _, f := range q.Fields {
+		if !isStu415Field(f) {
+			return fmt.Errorf("query: unknown field %q", f)
+		}
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("query: limit %d and offset %d can't be negative", q.Limit, q.Offset)
+	}
+	return nil
+}
+
+// Columns returns Fields, or Stu415Fields if no projection is set
+func (q *Query) Columns() []string {
+	if len(q.Fields) == 0 {
+		return Stu415Fields
+	}
+	return q.Fields
+}
+
+// ParseFieldList splits a comma separated list of field names, dropping blanks
+func ParseFieldList(list string) []string {
+	var fields []string
+	for _, f := range strings.Split(list, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func isStu415Field(name string) bool {
+	return new(Stu415).Field(name) != nil
+}
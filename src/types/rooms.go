@@ -0,0 +1,224 @@
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+type (
+	// RoomSection is a section meeting in a room
+	RoomSection struct {
+		SyncID   string `json:"sync_id"`
+		Course   string `json:"course"`
+		Teacher  string `json:"teacher"`
+		MeetDays string `json:"meet_days,omitempty"`
+		Students int    `json:"students"`
+	}
+
+	// RoomUse is what meets in a room during one period and quarter. Term is the quarter, or
+	// the section's term if it is not recognized. A room is double booked when sections of
+	// different teachers meet on the same days; cross-listed sections of one teacher are not
+	RoomUse struct {
+		School       string         `json:"school"`
+		Room         string         `json:"room"`
+		Per          string         `json:"per"`
+		Term         string         `json:"term,omitempty"`
+		Sections     []*RoomSection `json:"sections"`
+		Students     int            `json:"students"`
+		DoubleBooked bool           `json:"double_booked,omitempty"`
+	}
+
+	// EmptyRooms lists a school's rooms with nothing scheduled in a period and quarter
+	EmptyRooms struct {
+		School string   `json:"school"`
+		Per    string   `json:"per"`
+		Term   string   `json:"term,omitempty"`
+		Rooms  []string `json:"rooms"`
+	}
+
+	// RoomReport shows how a roster uses its rooms. Rooms are those named by any section of
+	// the school; rows without a room are left out
+	RoomReport struct {
+		Uses  []*RoomUse    `json:"uses"`
+		Empty []*EmptyRooms `json:"empty"`
+	}
+)
+
+// RoomUtilization groups s415s by school, room, period and quarter, counting each section's
+// students and flagging double booked rooms, and lists the rooms free in each period and
+// quarter the school schedules. A section meets in every quarter its term spans
+func RoomUtilization(s415s Stu415s) *RoomReport {
+	r := &RoomReport{Uses: []*RoomUse{}, Empty: []*EmptyRooms{}}
+	uses := make(map[string]*RoomUse)
+	sections := make(map[string]*RoomSection)
+	students := make(map[string]map[string]bool)
+	rooms := make(map[string]map[string]bool)
+	slots := make(map[string]*EmptyRooms)
+	used := make(map[string]map[string]bool)
+
+	for _, s := range s415s {
+		room := strings.TrimSpace(s.Room)
+		if room == "" {
+			continue
+		}
+		if rooms[s.OrganizationName] == nil {
+			rooms[s.OrganizationName] = make(map[string]bool)
+		}
+		rooms[s.OrganizationName][room] = true
+
+		for _, term := range slotTerms(s) {
+			slot := strings.Join([]string{s.OrganizationName, s.Per, term}, syncIDSep)
+			if _, ok := slots[slot]; !ok {
+				slots[slot] = &EmptyRooms{School: s.OrganizationName, Per: s.Per, Term: term}
+				used[slot] = make(map[string]bool)
+			}
+			used[slot][room] = true
+
+			uk := slot + syncIDSep + room
+			u, ok := uses[uk]
+			if !ok {
+				u = &RoomUse{School: s.OrganizationName, Room: room, Per: s.Per, Term: term}
+				uses[uk] = u
+				students[uk] = make(map[string]bool)
+				r.Uses = append(r.Uses, u)
+			}
+			sk := uk + syncIDSep + sectionKey(s)
+			sec, ok := sections[sk]
+			if !ok {
+				sec = &RoomSection{SyncID: s.SyncID, Course: s.CourseIDAndTitle, Teacher: s.Teacher, MeetDays: s.MeetDays}
+				sections[sk] = sec
+				students[sk] = make(map[string]bool)
+				u.Sections = append(u.Sections, sec)
+			}
+			if hasStudent(s) && !students[sk][s.PermID] {
+				students[sk][s.PermID] = true
+				sec.Students++
+			}
+			if hasStudent(s) && !students[uk][s.PermID] {
+				students[uk][s.PermID] = true
+				u.Students++
+			}
+		}
+	}
+
+	for _, u := range r.Uses {
+		u.DoubleBooked = doubleBooked(u.Sections)
+		sort.SliceStable(u.Sections, func(i, j int) bool {
+			if u.Sections[i].Teacher != u.Sections[j].Teacher {
+				return u.Sections[i].Teacher < u.Sections[j].Teacher
+			}
+			return u.Sections[i].Course < u.Sections[j].Course
+		})
+	}
+	sort.SliceStable(r.Uses, func(i, j int) bool {
+		a, b := r.Uses[i], r.Uses[j]
+		if a.School != b.School {
+			return a.School < b.School
+		}
+		if a.Room != b.Room {
+			return a.Room < b.Room
+		}
+		if pa, pb := ParsePeriod(a.Per), ParsePeriod(b.Per); pa != pb {
+			return pa.Less(pb)
+		}
+		return a.Term < b.Term
+	})
+
+	for slot, e := range slots {
+		e.Rooms = []string{}
+		for room := range rooms[e.School] {
+			if !used[slot][room] {
+				e.Rooms = append(e.Rooms, room)
+			}
+		}
+		sort.Strings(e.Rooms)
+		r.Empty = append(r.Empty, e)
+	}
+	sort.SliceStable(r.Empty, func(i, j int) bool {
+		a, b := r.Empty[i], r.Empty[j]
+		if a.School != b.School {
+			return a.School < b.School
+		}
+		if pa, pb := ParsePeriod(a.Per), ParsePeriod(b.Per); pa != pb {
+			return pa.Less(pb)
+		}
+		return a.Term < b.Term
+	})
+	return r
+}
+
+// slotTerms returns the quarters s meets in, or its term if it is not recognized
+func slotTerms(s *Stu415) []string {
+	qs := s.TermCode().Quarters()
+	if len(qs) == 0 {
+		return []string{s.Term}
+	}
+	terms := make([]string, len(qs))
+	for i, q := range qs {
+		terms[i] = q.String()
+	}
+	return terms
+}
+
+// doubleBooked is true if sections of different teachers meet on the same day
+func doubleBooked(sections []*RoomSection) bool {
+	for i := 0; i < len(sections); i++ {
+		for j := i + 1; j < len(sections); j++ {
+			a, b := sections[i], sections[j]
+			if a.Teacher != b.Teacher && ParseMeetDays(a.MeetDays).Overlaps(ParseMeetDays(b.MeetDays)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DoubleBooked returns the room uses with clashing sections
+func (r *RoomReport) DoubleBooked() []*RoomUse {
+	out := make([]*RoomUse, 0)
+	for _, u := range r.Uses {
+		if u.DoubleBooked {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// Filter keeps the uses of room and the uses and empty rooms of period and term, and with a
+// room only the periods it is free; empty arguments match all. A semester or year term
+// matches each of its quarters
+func (r *RoomReport) Filter(room, per, term string) *RoomReport {
+	out := &RoomReport{Uses: []*RoomUse{}, Empty: []*EmptyRooms{}}
+	for _, u := range r.Uses {
+		if (room == "" || strings.EqualFold(u.Room, room)) && (per == "" || u.Per == per) && inTerm(u.Term, term) {
+			out.Uses = append(out.Uses, u)
+		}
+	}
+	for _, e := range r.Empty {
+		if (per != "" && e.Per != per) || !inTerm(e.Term, term) {
+			continue
+		}
+		if room == "" {
+			out.Empty = append(out.Empty, e)
+			continue
+		}
+		for _, free := range e.Rooms {
+			if strings.EqualFold(free, room) {
+				out.Empty = append(out.Empty, &EmptyRooms{School: e.School, Per: e.Per, Term: e.Term, Rooms: []string{free}})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// inTerm is true if the slot term is within term, or term is empty
+func inTerm(slot, term string) bool {
+	if term == "" {
+		return true
+	}
+	if t := ParseTerm(term); t != TermUnknown {
+		return ParseTerm(slot).Overlaps(t)
+	}
+	return strings.EqualFold(slot, term)
+}
@@ -0,0 +1,171 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+// roomRow returns an enrollment of perm in a section meeting in room
+func roomRow(room, per, term, days, course, teacher, perm string) *Stu415 {
+	return &Stu415{
+		OrganizationName: "HS",
+		SchoolYear:       "2021",
+		PermID:           perm,
+		Per:              per,
+		Term:             term,
+		MeetDays:         days,
+		CourseIDAndTitle: course,
+		SectionID:        course,
+		SyncID:           course,
+		Teacher:          teacher,
+		Room:             room,
+	}
+}
+
+func TestRoomUtilization(t *testing.T) {
+	tests := []struct {
+		name   string
+		s415s  Stu415s
+		double []string          // room per term of each double booked use
+		empty  map[string]string // per term -> free rooms
+	}{
+		{
+			name: "year and semester clash",
+			s415s: Stu415s{
+				roomRow("101", "1", "YR", "MTWRF", "Algebra", "a@aps.edu", "1"),
+				roomRow("101", "1", "S1", "MTWRF", "Art", "b@aps.edu", "2"),
+				roomRow("102", "1", "YR", "MTWRF", "Bio", "c@aps.edu", "3"),
+			},
+			double: []string{"101 1 Q1", "101 1 Q2"},
+			empty:  map[string]string{"1 Q1": "", "1 Q2": "", "1 Q3": "", "1 Q4": ""},
+		},
+		{
+			name: "semesters share a room",
+			s415s: Stu415s{
+				roomRow("101", "1", "S1", "MTWRF", "Algebra", "a@aps.edu", "1"),
+				roomRow("101", "1", "S2", "MTWRF", "Art", "b@aps.edu", "2"),
+				roomRow("102", "1", "YR", "MTWRF", "Bio", "c@aps.edu", "3"),
+			},
+			empty: map[string]string{"1 Q1": "", "1 Q2": "", "1 Q3": "", "1 Q4": ""},
+		},
+		{
+			name: "a and b days",
+			s415s: Stu415s{
+				roomRow("101", "1", "YR", "A", "Algebra", "a@aps.edu", "1"),
+				roomRow("101", "1", "YR", "B", "Art", "b@aps.edu", "2"),
+			},
+			empty: map[string]string{"1 Q1": "", "1 Q2": "", "1 Q3": "", "1 Q4": ""},
+		},
+		{
+			name: "same days",
+			s415s: Stu415s{
+				roomRow("101", "1", "Q1", "MWF", "Algebra", "a@aps.edu", "1"),
+				roomRow("101", "1", "Q1", "M-F", "Art", "b@aps.edu", "2"),
+				roomRow("101", "1", "Q1", "TR", "Bio", "b@aps.edu", "3"),
+			},
+			double: []string{"101 1 Q1"},
+			empty:  map[string]string{"1 Q1": ""},
+		},
+		{
+			name: "cross-listed sections of one teacher",
+			s415s: Stu415s{
+				roomRow("101", "1", "YR", "MTWRF", "Algebra", "a@aps.edu", "1"),
+				roomRow("101", "1", "YR", "MTWRF", "Algebra Honors", "a@aps.edu", "2"),
+			},
+			empty: map[string]string{"1 Q1": "", "1 Q2": "", "1 Q3": "", "1 Q4": ""},
+		},
+		{
+			name: "free rooms by quarter",
+			s415s: Stu415s{
+				roomRow("101", "1", "S1", "MTWRF", "Algebra", "a@aps.edu", "1"),
+				roomRow("102", "1", "YR", "MTWRF", "Bio", "c@aps.edu", "3"),
+				roomRow("103", "2", "Q4", "MTWRF", "Art", "b@aps.edu", "2"),
+				roomRow("", "3", "YR", "MTWRF", "Lunch", "d@aps.edu", "2"),
+			},
+			empty: map[string]string{"1 Q1": "103", "1 Q2": "103", "1 Q3": "101 103", "1 Q4": "101 103", "2 Q4": "101 102"},
+		},
+		{
+			name: "unknown terms",
+			s415s: Stu415s{
+				roomRow("101", "1", "T1", "MTWRF", "Algebra", "a@aps.edu", "1"),
+				roomRow("101", "1", "T1", "MTWRF", "Art", "b@aps.edu", "2"),
+				roomRow("101", "1", "T2", "MTWRF", "Bio", "c@aps.edu", "3"),
+			},
+			double: []string{"101 1 T1"},
+			empty:  map[string]string{"1 T1": "", "1 T2": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := RoomUtilization(tt.s415s)
+			var double []string
+			for _, u := range r.DoubleBooked() {
+				double = append(double, u.Room+" "+u.Per+" "+u.Term)
+			}
+			if !reflect.DeepEqual(double, tt.double) {
+				t.Errorf("double booked %q, want %q", double, tt.double)
+			}
+			empty := make(map[string]string)
+			for _, e := range r.Empty {
+				rooms := ""
+				for i, room := range e.Rooms {
+					if i > 0 {
+						rooms += " "
+					}
+					rooms += room
+				}
+				empty[e.Per+" "+e.Term] = rooms
+			}
+			if !reflect.DeepEqual(empty, tt.empty) {
+				t.Errorf("empty %v, want %v", empty, tt.empty)
+			}
+		})
+	}
+}
+
+func TestRoomUtilizationCounts(t *testing.T) {
+	r := RoomUtilization(Stu415s{
+		roomRow("101", "1", "S1", "MTWRF", "Algebra", "a@aps.edu", "1"),
+		roomRow("101", "1", "S1", "MTWRF", "Algebra", "a@aps.edu", "2"),
+		roomRow("101", "1", "S1", "MTWRF", "Algebra Honors", "a@aps.edu", "2"),
+		roomRow("101", "1", "S1", "MTWRF", "Empty", "a@aps.edu", ""),
+	})
+	if len(r.Uses) != 2 {
+		t.Fatalf("%d uses, want 2", len(r.Uses))
+	}
+	u := r.Uses[0]
+	if u.Term != "Q1" || u.Students != 2 || len(u.Sections) != 3 {
+		t.Fatalf("use %s: %d students in %d sections", u.Term, u.Students, len(u.Sections))
+	}
+	got := []int{u.Sections[0].Students, u.Sections[1].Students, u.Sections[2].Students}
+	if want := []int{2, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("section students %v, want %v", got, want)
+	}
+}
+
+func TestRoomReportFilter(t *testing.T) {
+	r := RoomUtilization(Stu415s{
+		roomRow("101", "1", "S1", "MTWRF", "Algebra", "a@aps.edu", "1"),
+		roomRow("102", "1", "YR", "MTWRF", "Bio", "c@aps.edu", "2"),
+		roomRow("101", "2", "Q3", "MTWRF", "Art", "b@aps.edu", "3"),
+	})
+	tests := []struct {
+		room, per, term string
+		uses, empty     int
+	}{
+		{"", "", "", 7, 5},
+		{"101", "", "", 3, 2},
+		{"", "1", "", 6, 4},
+		{"", "", "Q3", 2, 2},
+		{"", "", "S2", 3, 3},
+		{"", "", "Semester 1", 4, 2},
+		{"101", "", "Q2", 1, 0},
+		{"", "", "T1", 0, 0},
+	}
+	for _, tt := range tests {
+		f := r.Filter(tt.room, tt.per, tt.term)
+		if len(f.Uses) != tt.uses || len(f.Empty) != tt.empty {
+			t.Errorf("Filter(%q, %q, %q): %d uses, %d empty; want %d, %d", tt.room, tt.per, tt.term, len(f.Uses), len(f.Empty), tt.uses, tt.empty)
+		}
+	}
+}
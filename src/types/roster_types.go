@@ -1,9 +1,8 @@
 package types
 
 import (
-	"encoding/hex"
 	"fmt"
-	"hash/fnv"
+	"sort"
 	"strings"
 )
 
@@ -23,6 +22,7 @@ type (
 		CourseIDAndTitle string `json:"course_id_and_title,omitempty"`
 		MeetDays         string `json:"meet_days,omitempty"`
 		Teacher          string `json:"teacher,omitempty"`
+		TeacherName      string `json:"teacher_name,omitempty"`
 		Room             string `json:"room,omitempty"`
 		Prescheduled     string `json:"prescheduled,omitempty"`
 		SyncID           string `json:"sync_id,omitempty"`
@@ -31,27 +31,37 @@ type (
 	// Stu415s is a list of Stu415
 	Stu415s []*Stu415
 
-	// Class groups Stu415s by period
+	// Class groups Stu415s by section. Role is the requesting staff member's role in it, if known
 	Class struct {
-		ID       string  `json:"id,omitempty"`
-		Title    string  `json:"title,omitempty"`
-		Per      string  `json:"per,omitempty"`
-		Teacher  string  `json:"teacher,omitempty"`
-		Students Stu415s `json:"students,omitempty"`
+		ID        string  `json:"id,omitempty"`
+		Title     string  `json:"title,omitempty"`
+		Per       string  `json:"per,omitempty"`
+		Teacher   string  `json:"teacher,omitempty"`
+		SectionID string  `json:"section_id,omitempty"`
+		Term      string  `json:"term,omitempty"`
+		Room      string  `json:"room,omitempty"`
+		MeetDays  string  `json:"meet_days,omitempty"`
+		Role      string  `json:"role,omitempty"`
+		Students  Stu415s `json:"students,omitempty"`
+	}
+
+	// ClassGroup holds cross-listed classes that share a teacher, period and room
+	ClassGroup struct {
+		Teacher string   `json:"teacher,omitempty"`
+		Per     string   `json:"per,omitempty"`
+		Room    string   `json:"room,omitempty"`
+		Classes []*Class `json:"classes,omitempty"`
 	}
 )
 
-// SetSyncIDs hashes the stu415 props to create new sync id
-func (s415s Stu415s) SetSyncIDs() error {
+// SetSyncIDs hashes the stu415 props with the provided scheme to create new sync id
+func (s415s Stu415s) SetSyncIDs(scheme SyncIDScheme) error {
 	if len(s415s) == 0 {
 		return fmt.Errorf("no students to create syncid")
 	}
-	enc := fnv.New32()
 
 	for _, s := range s415s {
-		enc.Write([]byte(s.Per + s.CourseIDAndTitle + s.SectionID + s.Term))
-		s.SyncID = hex.EncodeToString(enc.Sum(nil))
-		enc.Reset()
+		s.SyncID = scheme.ID(s)
 	}
 	return nil
 }
@@ -59,53 +69,120 @@ func (s415s Stu415s) SetSyncIDs() error {
 // Stu415sToClass takes Stu415s and returns a class based on first element
 func Stu415sToClass(s415s Stu415s) *Class {
 	s := s415s[0]
+	s415s.SortByName()
 	return &Class{
-		ID:       s.SyncID,
-		Title:    s.CourseIDAndTitle,
-		Per:      s.Per,
-		Teacher:  s.Teacher,
-		Students: s415s,
+		ID:        s.SyncID,
+		Title:     s.CourseIDAndTitle,
+		Per:       s.Per,
+		Teacher:   s.Teacher,
+		SectionID: s.SectionID,
+		Term:      s.Term,
+		Room:      s.Room,
+		MeetDays:  s.MeetDays,
+		Students:  s415s,
+	}
+}
+
+// sectionKey identifies a teacher's section in a school and year: the sync id, or section
+// fields if it is not set
+func sectionKey(s *Stu415) string {
+	if s.SyncID != "" {
+		return strings.Join([]string{s.OrganizationName, s.SchoolYear, s.Teacher, s.SyncID}, syncIDSep)
 	}
+	return strings.Join([]string{s.OrganizationName, s.SchoolYear, s.Teacher, s.Per, s.SectionID, s.Term, s.CourseIDAndTitle}, syncIDSep)
 }
 
-// Stu415sToClasses takes students and groups them by periods
+// Stu415sToClasses takes students and groups them by section
 func Stu415sToClasses(s415s Stu415s) []*Class {
-	// Group students by period
-	periods := make(map[string]Stu415s)
+	sections := make(map[string]Stu415s)
+	var order []string
 	for _, s := range s415s {
-		if r, ok := periods[s.Per]; !ok {
-			periods[s.Per] = Stu415s{s}
-		} else {
-			periods[s.Per] = append(r, s)
+		k := sectionKey(s)
+		if _, ok := sections[k]; !ok {
+			order = append(order, k)
 		}
+		sections[k] = append(sections[k], s)
 	}
 
-	var rosters = make([]*Class, 0)
-	// For each period, create a roster
-	for _, students := range periods {
-		r := &Class{
-			ID:       students[0].SyncID,
-			Title:    students[0].CourseIDAndTitle,
-			Per:      students[0].Per,
-			Teacher:  students[0].Teacher,
-			Students: students,
-		}
-		rosters = append(rosters, r)
+	var rosters = make([]*Class, 0, len(order))
+	for _, k := range order {
+		rosters = append(rosters, Stu415sToClass(sections[k]))
 	}
+	SortClasses(rosters)
 	return rosters
 }
 
-// TeacherNameToEmail takes a [email,name] list and changes s.Teacher to their lowercase email
-func (s415s Stu415s) TeacherNameToEmail(emails [][]string) {
-	nameEmail := make(map[string]string, len(emails))
-	for _, r := range emails {
-		nameEmail[r[1]] = strings.ToLower(r[0])
-	}
-	for _, s := range s415s {
-		if email, ok := nameEmail[s.Teacher]; ok {
-			s.Teacher = email
+// GroupCrossListed groups classes sharing a teacher, period and room, ordered by period
+func GroupCrossListed(classes []*Class) []*ClassGroup {
+	groups := make(map[string]*ClassGroup)
+	var list []*ClassGroup
+	for _, c := range classes {
+		k := strings.Join([]string{c.Teacher, c.Per, c.Room}, syncIDSep)
+		g, ok := groups[k]
+		if !ok {
+			g = &ClassGroup{Teacher: c.Teacher, Per: c.Per, Room: c.Room}
+			groups[k] = g
+			list = append(list, g)
 		}
+		g.Classes = append(g.Classes, c)
 	}
+	sort.SliceStable(list, func(i, j int) bool {
+		pi, pj := ParsePeriod(list[i].Per), ParsePeriod(list[j].Per)
+		if pi != pj {
+			return pi.Less(pj)
+		}
+		if list[i].Teacher != list[j].Teacher {
+			return list[i].Teacher < list[j].Teacher
+		}
+		return list[i].Room < list[j].Room
+	})
+	return list
+}
+
+// IsCrossListed is true if more than one section meets together
+func (g *ClassGroup) IsCrossListed() bool {
+	return len(g.Classes) > 1
+}
+
+// SortClasses orders classes by period ordinal, then title and section
+func SortClasses(classes []*Class) {
+	sort.SliceStable(classes, func(i, j int) bool {
+		pi, pj := classes[i].Period(), classes[j].Period()
+		if pi != pj {
+			return pi.Less(pj)
+		}
+		if classes[i].Title != classes[j].Title {
+			return classes[i].Title < classes[j].Title
+		}
+		return classes[i].SectionID < classes[j].SectionID
+	})
+}
+
+// SortByName orders stu415s by student name, then perm id
+func (s415s Stu415s) SortByName() {
+	sort.SliceStable(s415s, func(i, j int) bool {
+		if s415s[i].StudentName != s415s[j].StudentName {
+			return s415s[i].StudentName < s415s[j].StudentName
+		}
+		return s415s[i].PermID < s415s[j].PermID
+	})
+}
+
+// SortByPeriod orders stu415s by period ordinal, then student name
+func (s415s Stu415s) SortByPeriod() {
+	sort.SliceStable(s415s, func(i, j int) bool {
+		pi, pj := s415s[i].Period(), s415s[j].Period()
+		if pi != pj {
+			return pi.Less(pj)
+		}
+		return s415s[i].StudentName < s415s[j].StudentName
+	})
+}
+
+// TeacherNameToEmail takes a [email,name] list and changes s.Teacher to their lowercase email
+// Use MatchTeachers for overrides and a report of names that could not be matched
+func (s415s Stu415s) TeacherNameToEmail(emails [][]string) {
+	s415s.MatchTeachers(NewTeacherMatcher(emails, nil))
 }
 
 // ToList returns stu415s indexed by CourseIDAndTitle
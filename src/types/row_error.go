@@ -0,0 +1,76 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RowError describes a stu415 row that could not be stored. Row is its index in the
+// input and Field the column at fault, or "" if the database rejected the whole row
+type RowError struct {
+	Row    int    `json:"row"`
+	Field  string `json:"field,omitempty"`
+	PermID string `json:"perm_id,omitempty"`
+	SyncID string `json:"sync_id,omitempty"`
+	Err    error  `json:"-"`
+}
+
+func (e *RowError) Error() string {
+	field := ""
+	if e.Field != "" {
+		field = " " + e.Field
+	}
+	return fmt.Sprintf("row %d%s (perm %q, section %q): %v", e.Row, field, e.PermID, e.SyncID, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// RowErrors lists bad rows in input order
+type RowErrors []*RowError
+
+func (re RowErrors) Error() string {
+	lines := make([]string, len(re))
+	for i, e := range re {
+		lines[i] = e.Error()
+	}
+	return fmt.Sprintf("%d bad rows:\n  %s", len(re), strings.Join(lines, "\n  "))
+}
+
+// Validate returns a *RowError naming the first key field of s that is empty. A perm id
+// that is only PermIDDomain is empty. row is the index reported in the error. Sections
+// without a teacher are kept
+func (s *Stu415) Validate(row int) error {
+	for _, f := range []struct{ name, value string }{
+		{"perm_id", strings.TrimSuffix(strings.TrimSpace(s.PermID), PermIDDomain)},
+		{"course_id_and_title", s.CourseIDAndTitle},
+		{"sync_id", s.SyncID},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			return &RowError{Row: row, Field: f.name, PermID: s.PermID, SyncID: s.SyncID, Err: fmt.Errorf("missing %s", f.name)}
+		}
+	}
+	return nil
+}
+
+// CheckRows validates every row and returns the good ones with errors for the rest.
+// The blank student rows Synergy reports for empty sections are dropped without error.
+// If more than maxBad rows are bad the errors are returned as err instead
+func (s415s Stu415s) CheckRows(maxBad int) (good Stu415s, bad RowErrors, err error) {
+	good = make(Stu415s, 0, len(s415s))
+	for i, s := range s415s {
+		if !hasStudent(s) {
+			continue
+		}
+		if err := s.Validate(i); err != nil {
+			bad = append(bad, err.(*RowError))
+			continue
+		}
+		good = append(good, s)
+	}
+	if len(bad) > maxBad {
+		return nil, nil, bad
+	}
+	return good, bad, nil
+}
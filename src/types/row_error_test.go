@@ -0,0 +1,54 @@
+package types
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     Stu415
+		field string
+	}{
+		{"complete", Stu415{PermID: "1", CourseIDAndTitle: "Algebra", Teacher: "a@aps.edu", SyncID: "x"}, ""},
+		{"no teacher", Stu415{PermID: "1", CourseIDAndTitle: "Algebra", SyncID: "x"}, ""},
+		{"no perm id", Stu415{PermID: " ", CourseIDAndTitle: "Algebra", SyncID: "x"}, "perm_id"},
+		{"only perm id domain", Stu415{PermID: PermIDDomain, CourseIDAndTitle: "Algebra", SyncID: "x"}, "perm_id"},
+		{"no course", Stu415{PermID: "1", SyncID: "x"}, "course_id_and_title"},
+		{"no sync id", Stu415{PermID: "1", CourseIDAndTitle: "Algebra"}, "sync_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Validate(3)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			re, ok := err.(*RowError)
+			if !ok || re.Field != tt.field || re.Row != 3 {
+				t.Fatalf("Validate() = %v, want %s on row 3", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestCheckRows(t *testing.T) {
+	s415s := Stu415s{
+		{PermID: PermIDEmail("1"), CourseIDAndTitle: "Algebra", SyncID: "x"},
+		{PermID: PermIDDomain, CourseIDAndTitle: "Bio", SyncID: "y"},
+		{PermID: PermIDEmail("2"), SyncID: "x"},
+	}
+	good, bad, err := s415s.CheckRows(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(good) != 1 || good[0].PermID != PermIDEmail("1") {
+		t.Errorf("good rows %v, want perm 1 only", good)
+	}
+	if len(bad) != 1 || bad[0].Row != 2 || bad[0].Field != "course_id_and_title" {
+		t.Errorf("bad rows %v, want row 2 missing its course", bad)
+	}
+	if _, _, err := s415s.CheckRows(0); err == nil {
+		t.Error("CheckRows(0) kept a bad row")
+	}
+}
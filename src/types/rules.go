@@ -0,0 +1,206 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Rule actions
+const (
+	RuleCoTeacher = "co-teacher"
+	RuleReassign  = "reassign"
+	RuleExclude   = "exclude"
+	RuleClone     = "clone"
+)
+
+type (
+	// RuleMatch selects rows by LIKE-style patterns: % matches any run of characters
+	// and _ any one character. Empty patterns match every row
+	RuleMatch struct {
+		Course  string `json:"course,omitempty"`
+		School  string `json:"school,omitempty"`
+		Grade   string `json:"grade,omitempty"`
+		Per     string `json:"per,omitempty"`
+		Teacher string `json:"teacher,omitempty"`
+	}
+
+	// Rule changes matching rows after every update.
+	// co-teacher attaches Teacher to the section with Role (co-teacher if empty), reassign
+	// replaces its teacher, exclude drops the rows, and clone copies them into a virtual
+	// class titled Title taught by Teacher
+	Rule struct {
+		Name     string    `json:"name"`
+		Position int       `json:"position"`
+		Match    RuleMatch `json:"match"`
+		Action   string    `json:"action"`
+		Teacher  string    `json:"teacher,omitempty"`
+		Role     string    `json:"role,omitempty"`
+		Title    string    `json:"title,omitempty"`
+	}
+
+	// Rules run in Position order
+	Rules []*Rule
+
+	// RuleResult counts the rows a rule touched
+	RuleResult struct {
+		Name   string `json:"name"`
+		Action string `json:"action"`
+		Rows   int    `json:"rows"`
+	}
+
+	// RuleReport lists rule results in the order rules ran
+	RuleReport []*RuleResult
+)
+
+// ReadRules parses a JSON list of rules. Rules without a position run in file order
+func ReadRules(r io.Reader) (Rules, error) {
+	var rules Rules
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if rule.Position == 0 {
+			rule.Position = i + 1
+		}
+	}
+	return rules, rules.Validate()
+}
+
+// Validate returns an error for unknown actions or missing teachers and titles
+func (rules Rules) Validate() error {
+	for _, r := range rules {
+		switch r.Action {
+		case RuleCoTeacher, RuleReassign:
+			if r.Teacher == "" {
+				return fmt.Errorf("rule %s: %s needs a teacher", r.Name, r.Action)
+			}
+			if r.Role != "" && !ValidRole(r.Role) {
+				return fmt.Errorf("rule %s: unknown role %q", r.Name, r.Role)
+			}
+		case RuleClone:
+			if r.Teacher == "" && r.Title == "" {
+				return fmt.Errorf("rule %s: clone needs a teacher or title", r.Name)
+			}
+		case RuleExclude:
+		default:
+			return fmt.Errorf("rule %s: unknown action %q", r.Name, r.Action)
+		}
+	}
+	return nil
+}
+
+// Matches is true if s matches every pattern
+func (m RuleMatch) Matches(s *Stu415) bool {
+	return like(m.Course, s.CourseIDAndTitle) &&
+		like(m.School, s.OrganizationName) &&
+		like(m.Grade, s.Grade) &&
+		like(m.Per, s.Per) &&
+		like(m.Teacher, s.Teacher)
+}
+
+// Apply runs rules in Position order and returns the changed rows, the staff attached
+// to sections and a report. Each rule sees the rows produced by the rules before it
+func (rules Rules) Apply(s415s Stu415s) (Stu415s, []*SectionStaff, RuleReport) {
+	ordered := make(Rules, len(rules))
+	copy(ordered, rules)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Position < ordered[j].Position })
+
+	var staff []*SectionStaff
+	report := make(RuleReport, 0, len(ordered))
+	for _, r := range ordered {
+		var n int
+		s415s, staff, n = r.apply(s415s, staff)
+		report = append(report, &RuleResult{Name: r.Name, Action: r.Action, Rows: n})
+	}
+	return s415s, staff, report
+}
+
+func (r *Rule) apply(s415s Stu415s, staff []*SectionStaff) (Stu415s, []*SectionStaff, int) {
+	out := make(Stu415s, 0, len(s415s))
+	var added Stu415s
+	attached := make(map[string]bool)
+	n := 0
+	for _, s := range s415s {
+		if !r.Match.Matches(s) {
+			out = append(out, s)
+			continue
+		}
+		n++
+		switch r.Action {
+		case RuleExclude:
+		case RuleReassign:
+			s.Teacher, s.TeacherName = strings.ToLower(r.Teacher), ""
+			out = append(out, s)
+		case RuleCoTeacher:
+			out = append(out, s)
+			if k := s.OrganizationName + syncIDSep + s.SchoolYear + syncIDSep + s.SyncID; !attached[k] {
+				attached[k] = true
+				staff = append(staff, &SectionStaff{School: s.OrganizationName, Year: s.SchoolYear, SyncID: s.SyncID, Email: strings.ToLower(r.Teacher), Role: r.role(), Source: StaffFromRule})
+			}
+		case RuleClone:
+			c := *s
+			if r.Teacher != "" {
+				c.Teacher, c.TeacherName = strings.ToLower(r.Teacher), ""
+			}
+			if r.Title != "" {
+				c.CourseIDAndTitle = r.Title
+			}
+			c.SyncID = VirtualSyncID(r.Name, s.SyncID)
+			out = append(out, s)
+			added = append(added, &c)
+		}
+	}
+	return append(out, added...), staff, n
+}
+
+func (r *Rule) role() string {
+	if r.Role == "" {
+		return RoleCoTeacher
+	}
+	return r.Role
+}
+
+func (rr RuleReport) String() string {
+	var b strings.Builder
+	b.WriteString("roster rules:")
+	if len(rr) == 0 {
+		b.WriteString(" none configured")
+	}
+	b.WriteByte('\n')
+	for _, r := range rr {
+		fmt.Fprintf(&b, "  %-24s %-10s %d rows\n", r.Name, r.Action, r.Rows)
+	}
+	return b.String()
+}
+
+// like reports whether s matches a LIKE pattern, ignoring case
+func like(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	p, str := []rune(strings.ToLower(pattern)), []rune(strings.ToLower(s))
+	// star is the position after the last %, and mark the string position it matched from
+	pi, si, star, mark := 0, 0, -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '_' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '%':
+			star, mark = pi+1, si
+			pi++
+		case star >= 0:
+			mark++
+			pi, si = star, mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '%' {
+		pi++
+	}
+	return pi == len(p)
+}
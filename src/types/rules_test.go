@@ -0,0 +1,187 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLike(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "anything", true},
+		{"Algebra", "algebra", true},
+		{"Algebra", "Algebra 2", false},
+		{"%Advisory%", "080000 Advisory HS", true},
+		{"%advisory", "080000 Advisory HS", false},
+		{"08%", "080000 Advisory", true},
+		{"_", "3", true},
+		{"_", "10", false},
+		{"1_", "10", true},
+		{"%a%b%", "xaxxbx", true},
+		{"%a%b%", "xbxxax", false},
+		{"%%", "", true},
+		{"_", "", false},
+	}
+	for _, tt := range tests {
+		if got := like(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("like(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+// rulesRoster has HS advisories and an Algebra section shared with MS
+const rulesRoster = `
+HS,2021,"Lee, Bo",1,F,09,Year,1,YR,100,080000 Advisory,MTWRF,a@aps.edu,101,N
+HS,2021,"Lee, Bo",1,F,09,Year,2,YR,200,110000 Algebra,MTWRF,b@aps.edu,102,N
+HS,2021,"Kim, Al",2,M,10,Year,1,YR,101,080000 Advisory,MTWRF,a@aps.edu,101,N
+HS,2021,"Kim, Al",2,M,10,Year,2,YR,200,110000 Algebra,MTWRF,b@aps.edu,102,N
+MS,2021,"Cho, Di",3,F,07,Year,2,YR,200,110000 Algebra,MTWRF,b@aps.edu,202,N
+`
+
+func TestRulesApply(t *testing.T) {
+	unchanged := []string{
+		"HS 080000 Advisory a@aps.edu Lee, Bo",
+		"HS 110000 Algebra b@aps.edu Lee, Bo",
+		"HS 080000 Advisory a@aps.edu Kim, Al",
+		"HS 110000 Algebra b@aps.edu Kim, Al",
+		"MS 110000 Algebra b@aps.edu Cho, Di",
+	}
+	tests := []struct {
+		name   string
+		rules  Rules
+		rows   []string // school, course, teacher and student of each row out
+		staff  []string // school, course, email and role of each attached staff member
+		report []int    // rows touched by each rule in position order
+	}{
+		{name: "no rules", rows: unchanged, report: []int{}},
+		{
+			name:  "exclude",
+			rules: Rules{{Name: "no advisory", Match: RuleMatch{Course: "%advisory"}, Action: RuleExclude}},
+			rows: []string{
+				"HS 110000 Algebra b@aps.edu Lee, Bo",
+				"HS 110000 Algebra b@aps.edu Kim, Al",
+				"MS 110000 Algebra b@aps.edu Cho, Di",
+			},
+			report: []int{2},
+		},
+		{
+			name: "reassign runs after exclude by position",
+			rules: Rules{
+				{Name: "reassign", Position: 2, Match: RuleMatch{Grade: "1_"}, Action: RuleReassign, Teacher: "C@aps.edu"},
+				{Name: "exclude", Position: 1, Match: RuleMatch{School: "MS"}, Action: RuleExclude},
+			},
+			rows: []string{
+				"HS 080000 Advisory a@aps.edu Lee, Bo",
+				"HS 110000 Algebra b@aps.edu Lee, Bo",
+				"HS 080000 Advisory c@aps.edu Kim, Al",
+				"HS 110000 Algebra c@aps.edu Kim, Al",
+			},
+			report: []int{1, 2},
+		},
+		{
+			name: "co-teacher and aide once per section",
+			rules: Rules{
+				{Name: "co", Match: RuleMatch{School: "HS", Course: "11%"}, Action: RuleCoTeacher, Teacher: "C@aps.edu"},
+				{Name: "aide", Match: RuleMatch{Grade: "09"}, Action: RuleCoTeacher, Teacher: "d@aps.edu", Role: RoleAide},
+			},
+			rows: unchanged,
+			staff: []string{
+				"HS 110000 Algebra c@aps.edu " + RoleCoTeacher,
+				"HS 080000 Advisory d@aps.edu " + RoleAide,
+				"HS 110000 Algebra d@aps.edu " + RoleAide,
+			},
+			report: []int{2, 2},
+		},
+		{
+			name:   "co-teacher in each school's section",
+			rules:  Rules{{Name: "co", Match: RuleMatch{Course: "11%"}, Action: RuleCoTeacher, Teacher: "c@aps.edu"}},
+			rows:   unchanged,
+			staff:  []string{"HS 110000 Algebra c@aps.edu " + RoleCoTeacher, "MS 110000 Algebra c@aps.edu " + RoleCoTeacher},
+			report: []int{3},
+		},
+		{
+			name:   "clone",
+			rules:  Rules{{Name: "ms lab", Match: RuleMatch{School: "MS", Teacher: "b@%"}, Action: RuleClone, Teacher: "E@aps.edu", Title: "Algebra Lab"}},
+			rows:   append(unchanged[:len(unchanged):len(unchanged)], "MS Algebra Lab e@aps.edu Cho, Di"),
+			report: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s415s, staff, report := tt.rules.Apply(readStu415s(t, rulesRoster))
+			courses := make(map[string]string)
+			var rows []string
+			for _, s := range s415s {
+				courses[s.OrganizationName+" "+s.SyncID] = s.CourseIDAndTitle
+				rows = append(rows, s.OrganizationName+" "+s.CourseIDAndTitle+" "+s.Teacher+" "+s.StudentName)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows %q, want %q", rows, tt.rows)
+			}
+			var attached []string
+			for _, st := range staff {
+				attached = append(attached, st.School+" "+courses[st.School+" "+st.SyncID]+" "+st.Email+" "+st.Role)
+			}
+			if !reflect.DeepEqual(attached, tt.staff) {
+				t.Errorf("staff %q, want %q", attached, tt.staff)
+			}
+			n := []int{}
+			for _, r := range report {
+				n = append(n, r.Rows)
+			}
+			if !reflect.DeepEqual(n, tt.report) {
+				t.Errorf("report rows %v, want %v", n, tt.report)
+			}
+		})
+	}
+}
+
+func TestRulesApplyClone(t *testing.T) {
+	s415s := readStu415s(t, rulesRoster)
+	algebra := s415s[1].SyncID
+	rules := Rules{{Name: "lab", Match: RuleMatch{Course: "%Algebra", School: "HS"}, Action: RuleClone, Title: "Algebra Lab"}}
+	s415s, _, _ = rules.Apply(s415s)
+	if len(s415s) != 7 {
+		t.Fatalf("%d rows, want 7", len(s415s))
+	}
+	for _, clone := range s415s[5:] {
+		if clone.SyncID != VirtualSyncID("lab", algebra) || clone.Teacher != "b@aps.edu" || clone.CourseIDAndTitle != "Algebra Lab" {
+			t.Errorf("clone %+v", clone)
+		}
+	}
+	if s415s[1].SyncID != algebra || s415s[1].CourseIDAndTitle != "110000 Algebra" {
+		t.Errorf("clone changed the source row %+v", s415s[1])
+	}
+}
+
+func TestReadRules(t *testing.T) {
+	tests := []struct {
+		json string
+		err  string
+	}{
+		{`[{"name":"a","action":"exclude"},{"name":"b","position":9,"action":"reassign","teacher":"x@aps.edu"}]`, ""},
+		{`[{"name":"a","action":"drop"}]`, `unknown action "drop"`},
+		{`[{"name":"a","action":"co-teacher"}]`, "co-teacher needs a teacher"},
+		{`[{"name":"a","action":"co-teacher","teacher":"x@aps.edu","role":"boss"}]`, `unknown role "boss"`},
+		{`[{"name":"a","action":"clone"}]`, "clone needs a teacher or title"},
+		{`{"name":"a"}`, "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		rules, err := ReadRules(strings.NewReader(tt.json))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ReadRules(%s): %v, want %q", tt.json, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rules) != 2 || rules[0].Position != 1 || rules[1].Position != 9 {
+			t.Errorf("positions %d and %d, want 1 and 9", rules[0].Position, rules[1].Position)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package types
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type (
+	// Period is a parsed Per value: Num is the leading ordinal (-1 if none) and Label the raw value
+	Period struct {
+		Num   int    `json:"num"`
+		Label string `json:"label,omitempty"`
+	}
+
+	// TermCode enumerates the Synergy terms a section can meet in
+	TermCode int
+
+	// DaySet is a bitset of the days a section meets
+	DaySet uint8
+
+	// DateRange is an inclusive start and end date
+	DateRange struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	}
+
+	// TermCalendar maps terms to their date ranges for a school year
+	TermCalendar map[TermCode]DateRange
+)
+
+// Terms in the order they start
+const (
+	TermUnknown TermCode = iota
+	Q1
+	Q2
+	Q3
+	Q4
+	S1
+	S2
+	FullYear
+)
+
+// Meet days: M/T/W/R/F weekdays and A/B block days
+const (
+	Monday DaySet = 1 << iota
+	Tuesday
+	Wednesday
+	Thursday
+	Friday
+	ADay
+	BDay
+
+	Weekdays = Monday | Tuesday | Wednesday | Thursday | Friday
+)
+
+var termNames = map[TermCode]string{
+	TermUnknown: "",
+	Q1:          "Q1",
+	Q2:          "Q2",
+	Q3:          "Q3",
+	Q4:          "Q4",
+	S1:          "S1",
+	S2:          "S2",
+	FullYear:    "YR",
+}
+
+// termQuarters lists the quarters each term spans
+var termQuarters = map[TermCode][]TermCode{
+	Q1:       {Q1},
+	Q2:       {Q2},
+	Q3:       {Q3},
+	Q4:       {Q4},
+	S1:       {Q1, Q2},
+	S2:       {Q3, Q4},
+	FullYear: {Q1, Q2, Q3, Q4},
+}
+
+var dayLetters = []struct {
+	d DaySet
+	c byte
+}{
+	{Monday, 'M'}, {Tuesday, 'T'}, {Wednesday, 'W'}, {Thursday, 'R'}, {Friday, 'F'}, {ADay, 'A'}, {BDay, 'B'},
+}
+
+// ParsePeriod takes a Per value like "3", "10" or "3A" and returns its ordinal and label
+func ParsePeriod(per string) Period {
+	per = strings.TrimSpace(per)
+	i := 0
+	for i < len(per) && per[i] >= '0' && per[i] <= '9' {
+		i++
+	}
+	p := Period{Num: -1, Label: per}
+	if i > 0 {
+		p.Num, _ = strconv.Atoi(per[:i])
+	}
+	return p
+}
+
+// Less orders periods by ordinal, then label. Periods without a number sort last
+func (p Period) Less(o Period) bool {
+	if p.Num != o.Num {
+		if p.Num < 0 || o.Num < 0 {
+			return o.Num < 0
+		}
+		return p.Num < o.Num
+	}
+	return p.Label < o.Label
+}
+
+func (p Period) String() string {
+	return p.Label
+}
+
+// ParseTerm reads a Synergy term code ("Q1", "S2", "YR") or name ("Semester 1", "Full Year")
+func ParseTerm(term string) TermCode {
+	t := strings.ToUpper(strings.Join(strings.Fields(term), ""))
+	switch t {
+	case "Q1", "QUARTER1", "1STQUARTER":
+		return Q1
+	case "Q2", "QUARTER2", "2NDQUARTER":
+		return Q2
+	case "Q3", "QUARTER3", "3RDQUARTER":
+		return Q3
+	case "Q4", "QUARTER4", "4THQUARTER":
+		return Q4
+	case "S1", "SEM1", "SEMESTER1", "1STSEMESTER":
+		return S1
+	case "S2", "SEM2", "SEMESTER2", "2NDSEMESTER":
+		return S2
+	case "YR", "Y", "FY", "YEAR", "FULLYEAR", "YEARLONG":
+		return FullYear
+	}
+	return TermUnknown
+}
+
+// ParseTermFields uses Term and falls back to TermName if Term is not recognized
+func ParseTermFields(term, termName string) TermCode {
+	if t := ParseTerm(term); t != TermUnknown {
+		return t
+	}
+	return ParseTerm(termName)
+}
+
+func (t TermCode) String() string {
+	return termNames[t]
+}
+
+// Quarters returns the quarters t spans
+func (t TermCode) Quarters() []TermCode {
+	return termQuarters[t]
+}
+
+// Overlaps is true if t and o share a quarter
+func (t TermCode) Overlaps(o TermCode) bool {
+	for _, a := range t.Quarters() {
+		for _, b := range o.Quarters() {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseMeetDays reads letters like "MTWRF", "M,W,F", "A/B" or a range like "M-F"
+func ParseMeetDays(days string) DaySet {
+	var ds DaySet
+	days = strings.ToUpper(strings.TrimSpace(days))
+	if days == "M-F" || days == "DAILY" {
+		return Weekdays
+	}
+	for _, r := range days {
+		if unicode.IsSpace(r) || r == ',' || r == '/' {
+			continue
+		}
+		for _, dl := range dayLetters {
+			if byte(r) == dl.c {
+				ds |= dl.d
+			}
+		}
+	}
+	return ds
+}
+
+// Has is true if every day in o is in ds
+func (ds DaySet) Has(o DaySet) bool {
+	return ds&o == o
+}
+
+// Overlaps is true if ds and o share a day. An empty set is treated as meeting every day
+func (ds DaySet) Overlaps(o DaySet) bool {
+	if ds == 0 || o == 0 {
+		return true
+	}
+	return ds&o != 0
+}
+
+func (ds DaySet) String() string {
+	var b strings.Builder
+	for _, dl := range dayLetters {
+		if ds&dl.d != 0 {
+			b.WriteByte(dl.c)
+		}
+	}
+	return b.String()
+}
+
+// Contains is true if t falls on a day within the range
+func (dr DateRange) Contains(t time.Time) bool {
+	return !t.Before(dr.Start) && t.Before(dr.End.AddDate(0, 0, 1))
+}
+
+// ReadTermCalendar parses "term,start,end" csv rows with dates as YYYY-MM-DD
+func ReadTermCalendar(r io.Reader) (TermCalendar, error) {
+	csvR := csv.NewReader(r)
+	csvR.Comment = '#'
+	csvR.FieldsPerRecord = 3
+	records, err := csvR.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	tc := make(TermCalendar, len(records))
+	for i, rec := range records {
+		t := ParseTerm(rec[0])
+		if t == TermUnknown {
+			return nil, fmt.Errorf("term calendar line %d: unknown term %q", i+1, rec[0])
+		}
+		start, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(rec[1]), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("term calendar line %d: %v", i+1, err)
+		}
+		end, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(rec[2]), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("term calendar line %d: %v", i+1, err)
+		}
+		tc[t] = DateRange{Start: start, End: end}
+	}
+	return tc, nil
+}
+
+// Range returns the dates of t. Semesters and the full year are built from quarters
+// when they are not configured directly
+func (tc TermCalendar) Range(t TermCode) (DateRange, bool) {
+	if dr, ok := tc[t]; ok {
+		return dr, true
+	}
+	qs := t.Quarters()
+	if len(qs) < 2 {
+		return DateRange{}, false
+	}
+	first, ok := tc[qs[0]]
+	if !ok {
+		return DateRange{}, false
+	}
+	last, ok := tc[qs[len(qs)-1]]
+	if !ok {
+		return DateRange{}, false
+	}
+	return DateRange{Start: first.Start, End: last.End}, true
+}
+
+// TermsOn returns the configured terms in session on day t
+func (tc TermCalendar) TermsOn(t time.Time) []TermCode {
+	var terms []TermCode
+	for tt := Q1; tt <= FullYear; tt++ {
+		if dr, ok := tc.Range(tt); ok && dr.Contains(t) {
+			terms = append(terms, tt)
+		}
+	}
+	return terms
+}
+
+// Period returns the parsed Per
+func (s *Stu415) Period() Period {
+	return ParsePeriod(s.Per)
+}
+
+// TermCode returns the parsed Term, falling back to TermName
+func (s *Stu415) TermCode() TermCode {
+	return ParseTermFields(s.Term, s.TermName)
+}
+
+// Days returns the parsed MeetDays
+func (s *Stu415) Days() DaySet {
+	return ParseMeetDays(s.MeetDays)
+}
+
+// Period returns the parsed class Per
+func (c *Class) Period() Period {
+	return ParsePeriod(c.Per)
+}
+
+// TermCode returns the parsed class Term
+func (c *Class) TermCode() TermCode {
+	return ParseTerm(c.Term)
+}
+
+// Days returns the parsed class MeetDays
+func (c *Class) Days() DaySet {
+	return ParseMeetDays(c.MeetDays)
+}
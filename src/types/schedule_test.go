@@ -0,0 +1,178 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		per  string
+		want Period
+	}{
+		{"3", Period{Num: 3, Label: "3"}},
+		{" 10 ", Period{Num: 10, Label: "10"}},
+		{"3A", Period{Num: 3, Label: "3A"}},
+		{"07", Period{Num: 7, Label: "07"}},
+		{"HR", Period{Num: -1, Label: "HR"}},
+		{"", Period{Num: -1}},
+	}
+	for _, tt := range tests {
+		if got := ParsePeriod(tt.per); got != tt.want {
+			t.Errorf("ParsePeriod(%q) = %+v, want %+v", tt.per, got, tt.want)
+		}
+	}
+}
+
+func TestPeriodLess(t *testing.T) {
+	pers := []string{"HR", "10", "3A", "2", "3", ""}
+	ps := make([]Period, len(pers))
+	for i, per := range pers {
+		ps[i] = ParsePeriod(per)
+	}
+	for i := 0; i < len(ps); i++ {
+		for j := i + 1; j < len(ps); j++ {
+			if ps[j].Less(ps[i]) {
+				ps[i], ps[j] = ps[j], ps[i]
+			}
+		}
+	}
+	var got []string
+	for _, p := range ps {
+		got = append(got, p.Label)
+	}
+	if want := []string{"2", "3", "3A", "10", "", "HR"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("periods sorted %q, want %q", got, want)
+	}
+}
+
+func TestParseTerm(t *testing.T) {
+	tests := []struct {
+		term string
+		want TermCode
+	}{
+		{"Q1", Q1},
+		{"q2", Q2},
+		{"Quarter 3", Q3},
+		{"4th Quarter", Q4},
+		{"S1", S1},
+		{"Semester 2", S2},
+		{"2nd Semester", S2},
+		{"YR", FullYear},
+		{"Full Year", FullYear},
+		{"year long", FullYear},
+		{"T1", TermUnknown},
+		{"", TermUnknown},
+	}
+	for _, tt := range tests {
+		if got := ParseTerm(tt.term); got != tt.want {
+			t.Errorf("ParseTerm(%q) = %v, want %v", tt.term, got, tt.want)
+		}
+	}
+	if got := ParseTermFields("T1", "Semester 1"); got != S1 {
+		t.Errorf("ParseTermFields fell back to %v, want S1", got)
+	}
+}
+
+func TestTermOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b TermCode
+		want bool
+	}{
+		{FullYear, S2, true},
+		{S1, S2, false},
+		{S1, Q2, true},
+		{Q3, Q4, false},
+		{TermUnknown, FullYear, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Overlaps(tt.b); got != tt.want {
+			t.Errorf("%v.Overlaps(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseMeetDays(t *testing.T) {
+	tests := []struct {
+		days string
+		want DaySet
+	}{
+		{"MTWRF", Weekdays},
+		{"M-F", Weekdays},
+		{"daily", Weekdays},
+		{"M,W,F", Monday | Wednesday | Friday},
+		{" tr ", Tuesday | Thursday},
+		{"A/B", ADay | BDay},
+		{"B", BDay},
+		{"", 0},
+		{"XYZ", 0},
+	}
+	for _, tt := range tests {
+		if got := ParseMeetDays(tt.days); got != tt.want {
+			t.Errorf("ParseMeetDays(%q) = %v, want %v", tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestDaySetOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"MWF", "M-F", true},
+		{"MWF", "TR", false},
+		{"A", "B", false},
+		{"A", "A/B", true},
+		{"", "TR", true},
+	}
+	for _, tt := range tests {
+		if got := ParseMeetDays(tt.a).Overlaps(ParseMeetDays(tt.b)); got != tt.want {
+			t.Errorf("%q overlaps %q = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTermCalendar(t *testing.T) {
+	tc, err := ReadTermCalendar(strings.NewReader(`# term,start,end
+Q1,2021-08-11,2021-10-08
+Q2,2021-10-18,2021-12-17
+Q3,2022-01-05,2022-03-11
+Q4,2022-03-21,2022-05-26
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dr, ok := tc.Range(S1); !ok || dr.Start.Format("2006-01-02") != "2021-08-11" || dr.End.Format("2006-01-02") != "2021-12-17" {
+		t.Errorf("S1 range %v, %v", dr, ok)
+	}
+	day := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		on   string
+		want []TermCode
+	}{
+		{"2021-08-11 08:00", []TermCode{Q1, S1, FullYear}},
+		{"2021-12-17 15:00", []TermCode{Q2, S1, FullYear}},
+		{"2021-12-25 12:00", []TermCode{FullYear}},
+		{"2022-05-26 23:59", []TermCode{Q4, S2, FullYear}},
+		{"2022-06-01 12:00", nil},
+	}
+	for _, tt := range tests {
+		if got := tc.TermsOn(day(tt.on)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TermsOn(%s) = %v, want %v", tt.on, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"T1,2021-08-11,2021-10-08\n", "Q1,8/11/2021,2021-10-08\n", "Q1,2021-08-11\n"} {
+		if _, err := ReadTermCalendar(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadTermCalendar(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package types
+
+// AnyYear in Scope.Year matches every school year
+const AnyYear = "*"
+
+// Scope limits roster queries to a school and school year. An empty School matches
+// every school and an empty Year means the current (latest) school year on the roster
+type Scope struct {
+	School string `json:"school,omitempty"`
+	Year   string `json:"year,omitempty"`
+}
+
+// Partition is one school's roster for one school year. Updates replace whole partitions
+type Partition struct {
+	School string `json:"school"`
+	Year   string `json:"year"`
+}
+
+// Partitions returns the distinct schools and years in s415s in first-seen order
+func (s415s Stu415s) Partitions() []Partition {
+	seen := make(map[Partition]bool)
+	var ps []Partition
+	for _, s := range s415s {
+		p := Partition{School: s.OrganizationName, Year: s.SchoolYear}
+		if !seen[p] {
+			seen[p] = true
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+func (p Partition) String() string {
+	return p.School + " " + p.Year
+}
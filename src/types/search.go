@@ -0,0 +1,41 @@
+package types
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Search hit kinds
+const (
+	SearchStudent = "student"
+	SearchStaff   = "staff"
+	SearchSection = "section"
+)
+
+// SearchHit is one match from the roster search index. Ref is a student perm id, staff
+// email or section sync id. Lower ranks are better matches
+type SearchHit struct {
+	Kind string  `json:"kind"`
+	Ref  string  `json:"ref"`
+	Rank float64 `json:"rank"`
+}
+
+// SearchResults are the students, staff and sections matching a search, best match first
+type SearchResults struct {
+	Students []*Student `json:"students,omitempty"`
+	Staff    []*Staff   `json:"staff,omitempty"`
+	Sections []*Class   `json:"sections,omitempty"`
+}
+
+// SearchTerms splits q into lowercase words, dropping punctuation. Each term matches the
+// start of a word, so "garc mar" finds "Garcia, Maria"
+func SearchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Len is the number of results of every kind
+func (r *SearchResults) Len() int {
+	return len(r.Students) + len(r.Staff) + len(r.Sections)
+}
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Section staff roles
+const (
+	RolePrimary   = "primary"
+	RoleCoTeacher = "co-teacher"
+	RoleAide      = "aide"
+	RoleObserver  = "observer"
+)
+
+// Section staff sources. Imported staff are kept across refreshes; the rest are rebuilt
+const (
+	StaffFromSynergy = "synergy"
+	StaffFromRule    = "rule"
+	StaffFromImport  = "import"
+)
+
+// Roles lists the valid section staff roles
+var Roles = []string{RolePrimary, RoleCoTeacher, RoleAide, RoleObserver}
+
+// SectionStaff attaches a staff member to a section with a role. A section is its sync id
+// within a school and year
+type SectionStaff struct {
+	School string `json:"school,omitempty"`
+	Year   string `json:"year,omitempty"`
+	SyncID string `json:"sync_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	Source string `json:"source"`
+}
+
+// ValidRole is true if role is one of Roles
+func ValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadSectionStaff parses "sync_id,email,role" csv rows. The role defaults to co-teacher
+func ReadSectionStaff(r io.Reader) ([]*SectionStaff, error) {
+	csvR := csv.NewReader(r)
+	csvR.Comment = '#'
+	csvR.FieldsPerRecord = -1
+	records, err := csvR.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	staff := make([]*SectionStaff, 0, len(records))
+	for i, rec := range records {
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("section staff line %d: want sync_id,email[,role]", i+1)
+		}
+		st := &SectionStaff{
+			SyncID: strings.TrimSpace(rec[0]),
+			Email:  strings.ToLower(strings.TrimSpace(rec[1])),
+			Role:   RoleCoTeacher,
+			Source: StaffFromImport,
+		}
+		if len(rec) > 2 && strings.TrimSpace(rec[2]) != "" {
+			st.Role = strings.ToLower(strings.TrimSpace(rec[2]))
+		}
+		// skip a header row
+		if i == 0 && !strings.Contains(st.Email, "@") {
+			continue
+		}
+		if !ValidRole(st.Role) {
+			return nil, fmt.Errorf("section staff line %d: unknown role %q", i+1, st.Role)
+		}
+		staff = append(staff, st)
+	}
+	return staff, nil
+}
@@ -0,0 +1,33 @@
+package types
+
+import "strings"
+
+// Staff is a row of the Synergy staff email report
+type Staff struct {
+	Email         string   `json:"email,omitempty"`
+	FormattedName string   `json:"formatted_name,omitempty"`
+	Extra         []string `json:"extra,omitempty"`
+}
+
+// StaffFromEmails takes a [email,name,...] list and returns staff with lowercase emails
+// The report header and repeated emails are skipped
+func StaffFromEmails(emails [][]string) []*Staff {
+	seen := make(map[string]bool, len(emails))
+	staff := make([]*Staff, 0, len(emails))
+	for _, r := range emails {
+		if len(r) < 2 {
+			continue
+		}
+		email := strings.ToLower(strings.TrimSpace(r[0]))
+		if !strings.Contains(email, "@") || seen[email] {
+			continue
+		}
+		seen[email] = true
+		staff = append(staff, &Staff{
+			Email:         email,
+			FormattedName: strings.TrimSpace(r[1]),
+			Extra:         r[2:],
+		})
+	}
+	return staff
+}
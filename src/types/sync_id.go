@@ -0,0 +1,102 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"strings"
+)
+
+// SyncIDVersion prefixes every sync id made by a SyncIDScheme.
+// Legacy ids are 8 hex characters with no prefix
+const SyncIDVersion = "v2"
+
+// syncIDSep separates hashed fields so "1"+"23" and "12"+"3" differ
+const syncIDSep = "\x1f"
+
+// SyncIDScheme selects the optional fields hashed into a sync id.
+// Per, CourseIDAndTitle, SectionID and Term are always included
+type SyncIDScheme struct {
+	School bool `json:"school,omitempty"`
+	Year   bool `json:"year,omitempty"`
+}
+
+// SyncIDSchemes lists every scheme variant, used to map ids made under other options
+var SyncIDSchemes = []SyncIDScheme{{}, {School: true}, {Year: true}, {School: true, Year: true}}
+
+// ID returns the versioned sync id of s: a 128-bit sha256 prefix of its delimited fields
+func (sc SyncIDScheme) ID(s *Stu415) string {
+	fields := []string{s.Per, s.CourseIDAndTitle, s.SectionID, s.Term}
+	if sc.School {
+		fields = append(fields, s.OrganizationName)
+	}
+	if sc.Year {
+		fields = append(fields, s.SchoolYear)
+	}
+	sum := sha256.Sum256([]byte(sc.String() + syncIDSep + strings.Join(fields, syncIDSep)))
+	return SyncIDVersion + "-" + hex.EncodeToString(sum[:16])
+}
+
+// String names the scheme, e.g. "v2", "v2+school+year"
+func (sc SyncIDScheme) String() string {
+	name := SyncIDVersion
+	if sc.School {
+		name += "+school"
+	}
+	if sc.Year {
+		name += "+year"
+	}
+	return name
+}
+
+// LegacySyncID returns the 32-bit FNV id used before versioned sync ids
+func LegacySyncID(s *Stu415) string {
+	enc := fnv.New32()
+	enc.Write([]byte(s.Per + s.CourseIDAndTitle + s.SectionID + s.Term))
+	return hex.EncodeToString(enc.Sum(nil))
+}
+
+// IsLegacySyncID is true for ids made before versioned sync ids
+func IsLegacySyncID(id string) bool {
+	return !strings.HasPrefix(id, SyncIDVersion+"-")
+}
+
+// SyncIDMapping links an id from an older scheme to its current sync id
+type SyncIDMapping struct {
+	OldID  string `json:"old_id"`
+	NewID  string `json:"new_id"`
+	Scheme string `json:"scheme"`
+}
+
+// SyncIDMappings returns the legacy and other-scheme ids of each section mapped to the ids set by
+// SetSyncIDs. Call it after SetSyncIDs
+func (s415s Stu415s) SyncIDMappings(current SyncIDScheme) []*SyncIDMapping {
+	seen := make(map[string]bool)
+	var maps []*SyncIDMapping
+	add := func(old, scheme, id string) {
+		if old == id || seen[old+syncIDSep+id] {
+			return
+		}
+		seen[old+syncIDSep+id] = true
+		maps = append(maps, &SyncIDMapping{OldID: old, NewID: id, Scheme: scheme})
+	}
+	for _, s := range s415s {
+		if seen[s.SyncID] {
+			continue
+		}
+		seen[s.SyncID] = true
+		add(LegacySyncID(s), "legacy", s.SyncID)
+		for _, sc := range SyncIDSchemes {
+			if sc != current {
+				add(sc.ID(s), sc.String(), s.SyncID)
+			}
+		}
+	}
+	return maps
+}
+
+// VirtualSyncID returns the sync id of a class cloned from syncID by the named rule
+func VirtualSyncID(rule, syncID string) string {
+	sum := sha256.Sum256([]byte("rule" + syncIDSep + rule + syncIDSep + syncID))
+	return SyncIDVersion + "-" + hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,347 @@
+package types
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Teacher match methods, from most to least certain
+const (
+	MatchOverride   = "override"
+	MatchExact      = "exact"
+	MatchNormalized = "normalized"
+	MatchFuzzy      = "fuzzy"
+	MatchAmbiguous  = "ambiguous"
+	MatchNone       = "unmatched"
+)
+
+// DefaultMinConfidence is the lowest fuzzy score accepted as a match
+const DefaultMinConfidence = 0.85
+
+// ambiguityMargin is how close a runner-up fuzzy score must be to make a match ambiguous
+const ambiguityMargin = 0.05
+
+var nameSuffixes = map[string]bool{
+	"jr": true, "sr": true, "ii": true, "iii": true, "iv": true,
+	"phd": true, "md": true, "edd": true, "esq": true,
+}
+
+type (
+	// TeacherMatch records how a Stu415 teacher display name was resolved to an email
+	TeacherMatch struct {
+		Name       string   `json:"name"`
+		Email      string   `json:"email,omitempty"`
+		Method     string   `json:"method"`
+		Confidence float64  `json:"confidence"`
+		Candidates []string `json:"candidates,omitempty"`
+		Rows       int      `json:"rows"`
+	}
+
+	// MatchReport groups the teacher names of an update by how they were matched
+	MatchReport struct {
+		Matched   []*TeacherMatch `json:"matched,omitempty"`
+		Fuzzy     []*TeacherMatch `json:"fuzzy,omitempty"`
+		Ambiguous []*TeacherMatch `json:"ambiguous,omitempty"`
+		Unmatched []*TeacherMatch `json:"unmatched,omitempty"`
+	}
+
+	// TeacherMatcher resolves teacher display names to staff emails
+	TeacherMatcher struct {
+		// MinConfidence is the lowest fuzzy score accepted as a match
+		MinConfidence float64
+
+		overrides map[string]string
+		exact     map[string]string
+		full      map[string][]string
+		short     map[string][]string
+	}
+
+	// personName is a display name split into comparable parts
+	personName struct {
+		first, middle, last string
+	}
+)
+
+// NewTeacherMatcher indexes a [email,name] list. overrides pins display names to emails and may be nil
+func NewTeacherMatcher(emails [][]string, overrides map[string]string) *TeacherMatcher {
+	m := &TeacherMatcher{
+		MinConfidence: DefaultMinConfidence,
+		overrides:     make(map[string]string, len(overrides)),
+		exact:         make(map[string]string, len(emails)),
+		full:          make(map[string][]string, len(emails)),
+		short:         make(map[string][]string, len(emails)),
+	}
+	for name, email := range overrides {
+		m.overrides[parseName(name).full()] = strings.ToLower(strings.TrimSpace(email))
+	}
+	for _, r := range emails {
+		if len(r) < 2 {
+			continue
+		}
+		email := strings.ToLower(strings.TrimSpace(r[0]))
+		if !strings.Contains(email, "@") {
+			// report header
+			continue
+		}
+		m.exact[r[1]] = email
+		pn := parseName(r[1])
+		m.full[pn.full()] = appendUnique(m.full[pn.full()], email)
+		m.short[pn.short()] = appendUnique(m.short[pn.short()], email)
+	}
+	return m
+}
+
+// Match resolves a display name. Unresolved names return a match without Email
+func (m *TeacherMatcher) Match(name string) *TeacherMatch {
+	tm := &TeacherMatch{Name: name}
+	pn := parseName(name)
+
+	if email, ok := m.overrides[pn.full()]; ok {
+		tm.Email, tm.Method, tm.Confidence = email, MatchOverride, 1
+		return tm
+	}
+	if email, ok := m.exact[name]; ok {
+		tm.Email, tm.Method, tm.Confidence = email, MatchExact, 1
+		return tm
+	}
+	for _, emails := range [][]string{m.full[pn.full()], m.short[pn.short()]} {
+		if len(emails) == 1 {
+			tm.Email, tm.Method, tm.Confidence = emails[0], MatchNormalized, 1
+			return tm
+		} else if len(emails) > 1 {
+			tm.Method, tm.Candidates = MatchAmbiguous, emails
+			return tm
+		}
+	}
+	return m.fuzzy(tm, pn)
+}
+
+// fuzzy scores name against every staff short name and accepts a clear winner
+func (m *TeacherMatcher) fuzzy(tm *TeacherMatch, pn personName) *TeacherMatch {
+	type scored struct {
+		email string
+		score float64
+	}
+	var scores []scored
+	key := pn.short()
+	for staff, emails := range m.short {
+		s := similarity(key, staff)
+		for _, e := range emails {
+			scores = append(scores, scored{e, s})
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].score != scores[j].score {
+			return scores[i].score > scores[j].score
+		}
+		return scores[i].email < scores[j].email
+	})
+
+	tm.Method = MatchNone
+	if len(scores) == 0 || scores[0].score < m.MinConfidence {
+		if len(scores) > 0 {
+			tm.Confidence = scores[0].score
+			tm.Candidates = []string{scores[0].email}
+		}
+		return tm
+	}
+	tm.Confidence = scores[0].score
+	for _, s := range scores {
+		if scores[0].score-s.score > ambiguityMargin {
+			break
+		}
+		tm.Candidates = append(tm.Candidates, s.email)
+	}
+	if len(tm.Candidates) > 1 {
+		tm.Method = MatchAmbiguous
+		return tm
+	}
+	tm.Email, tm.Method, tm.Candidates = scores[0].email, MatchFuzzy, nil
+	return tm
+}
+
+// MatchTeachers changes s.Teacher to the matched email and returns a report of every name seen
+// The original display name is kept in s.TeacherName
+func (s415s Stu415s) MatchTeachers(m *TeacherMatcher) *MatchReport {
+	seen := make(map[string]*TeacherMatch)
+	var order []string
+	for _, s := range s415s {
+		if s.TeacherName == "" {
+			s.TeacherName = s.Teacher
+		}
+		tm, ok := seen[s.Teacher]
+		if !ok {
+			tm = m.Match(s.Teacher)
+			seen[s.Teacher] = tm
+			order = append(order, s.Teacher)
+		}
+		tm.Rows++
+		if tm.Email != "" {
+			s.Teacher = tm.Email
+		}
+	}
+
+	sort.Strings(order)
+	report := new(MatchReport)
+	for _, name := range order {
+		tm := seen[name]
+		switch tm.Method {
+		case MatchFuzzy:
+			report.Fuzzy = append(report.Fuzzy, tm)
+		case MatchAmbiguous:
+			report.Ambiguous = append(report.Ambiguous, tm)
+		case MatchNone:
+			report.Unmatched = append(report.Unmatched, tm)
+		default:
+			report.Matched = append(report.Matched, tm)
+		}
+	}
+	return report
+}
+
+// OK is true when every name was matched with certainty
+func (mr *MatchReport) OK() bool {
+	return len(mr.Fuzzy) == 0 && len(mr.Ambiguous) == 0 && len(mr.Unmatched) == 0
+}
+
+func (mr *MatchReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "teacher matches: %d matched, %d fuzzy, %d ambiguous, %d unmatched\n",
+		len(mr.Matched), len(mr.Fuzzy), len(mr.Ambiguous), len(mr.Unmatched))
+	for _, tm := range mr.Fuzzy {
+		fmt.Fprintf(&b, "  fuzzy     %q -> %s (%.2f, %d rows)\n", tm.Name, tm.Email, tm.Confidence, tm.Rows)
+	}
+	for _, tm := range mr.Ambiguous {
+		fmt.Fprintf(&b, "  ambiguous %q -> %s (%d rows)\n", tm.Name, strings.Join(tm.Candidates, " | "), tm.Rows)
+	}
+	for _, tm := range mr.Unmatched {
+		fmt.Fprintf(&b, "  unmatched %q (%d rows)", tm.Name, tm.Rows)
+		if len(tm.Candidates) > 0 {
+			fmt.Fprintf(&b, " closest %s (%.2f)", tm.Candidates[0], tm.Confidence)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// ReadTeacherOverrides parses "name,email" csv rows that pin display names to emails
+func ReadTeacherOverrides(r io.Reader) (map[string]string, error) {
+	csvR := csv.NewReader(r)
+	csvR.Comment = '#'
+	csvR.FieldsPerRecord = 2
+	records, err := csvR.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]string, len(records))
+	for _, rec := range records {
+		overrides[rec[0]] = rec[1]
+	}
+	return overrides, nil
+}
+
+// parseName splits "Last, First M. Jr." or "First M. Last" into lowercase parts without punctuation
+func parseName(name string) personName {
+	name = strings.ToLower(name)
+	var pn personName
+	if i := strings.Index(name, ","); i >= 0 {
+		last := nameTokens(name[:i])
+		rest := nameTokens(name[i+1:])
+		pn.last = strings.Join(last, " ")
+		if len(rest) > 0 {
+			pn.first = rest[0]
+			pn.middle = strings.Join(rest[1:], " ")
+		}
+		return pn
+	}
+	toks := nameTokens(name)
+	switch len(toks) {
+	case 0:
+	case 1:
+		pn.last = toks[0]
+	default:
+		pn.first = toks[0]
+		pn.last = toks[len(toks)-1]
+		pn.middle = strings.Join(toks[1:len(toks)-1], " ")
+	}
+	return pn
+}
+
+// nameTokens strips punctuation and suffixes from a name part
+func nameTokens(s string) []string {
+	var toks []string
+	for _, f := range strings.Fields(s) {
+		f = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || r == '-' || r == '\'' {
+				return r
+			}
+			return -1
+		}, f)
+		if f == "" || nameSuffixes[f] {
+			continue
+		}
+		toks = append(toks, f)
+	}
+	return toks
+}
+
+func (pn personName) full() string {
+	return strings.Join(strings.Fields(pn.last+" "+pn.first+" "+pn.middle), " ")
+}
+
+// short drops the middle name, which staff lists and Synergy disagree on most
+func (pn personName) short() string {
+	return strings.TrimSpace(pn.last + " " + pn.first)
+}
+
+// similarity is 1 minus the levenshtein distance over the longer length
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	max := len(ra)
+	if len(rb) > max {
+		max = len(rb)
+	}
+	return 1 - float64(prev[len(rb)])/float64(max)
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, l := range list {
+		if l == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,102 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+// matchEmails is a staff report with a header row
+var matchEmails = [][]string{
+	{"email", "name"},
+	{"a.garcia@aps.edu", "Garcia, Ana"},
+	{"J.Smith@aps.edu", "Smith, John Q."},
+	{"john.smith@aps.edu", "Smith, John R."},
+	{"m.oconnor@aps.edu", "O'Connor, Mary"},
+	{"r.lee@aps.edu", "Lee, Robert Jr."},
+	{"k.johnson@aps.edu", "Johnson, Katherine"},
+	{"k.johnsen@aps.edu", "Johnsen, Katherine"},
+}
+
+func TestTeacherMatcherMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		overrides  map[string]string
+		email      string
+		method     string
+		candidates []string // checked when set
+	}{
+		{name: "Garcia, Ana", email: "a.garcia@aps.edu", method: MatchExact},
+		{name: "GARCIA, ANA", email: "a.garcia@aps.edu", method: MatchNormalized},
+		{name: "Ana Garcia", email: "a.garcia@aps.edu", method: MatchNormalized},
+		{name: "Smith, John Q", email: "j.smith@aps.edu", method: MatchNormalized},
+		{name: "Lee, Robert", email: "r.lee@aps.edu", method: MatchNormalized},
+		{
+			name:       "Smith, John",
+			method:     MatchAmbiguous,
+			candidates: []string{"j.smith@aps.edu", "john.smith@aps.edu"},
+		},
+		{
+			name:      "Smith, John",
+			overrides: map[string]string{"smith,  john": " J.Smith@APS.edu "},
+			email:     "j.smith@aps.edu",
+			method:    MatchOverride,
+		},
+		{name: "OConnor, Mary", email: "m.oconnor@aps.edu", method: MatchFuzzy},
+		{
+			name:       "Johnsan, Katherine",
+			method:     MatchAmbiguous,
+			candidates: []string{"k.johnsen@aps.edu", "k.johnson@aps.edu"},
+		},
+		{name: "Nobody, Here", method: MatchNone},
+		{name: "", method: MatchNone},
+	}
+	for _, tt := range tests {
+		tm := NewTeacherMatcher(matchEmails, tt.overrides).Match(tt.name)
+		if tm.Email != tt.email || tm.Method != tt.method {
+			t.Errorf("Match(%q) = %s %q, want %s %q", tt.name, tm.Method, tm.Email, tt.method, tt.email)
+		}
+		if tt.candidates != nil && !reflect.DeepEqual(tm.Candidates, tt.candidates) {
+			t.Errorf("Match(%q) candidates %q, want %q", tt.name, tm.Candidates, tt.candidates)
+		}
+	}
+}
+
+func TestTeacherMatcherMinConfidence(t *testing.T) {
+	m := NewTeacherMatcher(matchEmails, nil)
+	m.MinConfidence = 0.95
+	tm := m.Match("OConnor, Mary")
+	if tm.Method != MatchNone || tm.Email != "" || !reflect.DeepEqual(tm.Candidates, []string{"m.oconnor@aps.edu"}) {
+		t.Errorf("Match below MinConfidence = %+v", tm)
+	}
+}
+
+func TestMatchTeachers(t *testing.T) {
+	s415s := Stu415s{
+		{Teacher: "Garcia, Ana"},
+		{Teacher: "Garcia, Ana"},
+		{Teacher: "OConnor, Mary"},
+		{Teacher: "Smith, John"},
+		{Teacher: "Nobody, Here"},
+	}
+	r := s415s.MatchTeachers(NewTeacherMatcher(matchEmails, nil))
+	if r.OK() || len(r.Matched) != 1 || len(r.Fuzzy) != 1 || len(r.Ambiguous) != 1 || len(r.Unmatched) != 1 {
+		t.Fatalf("report %s", r)
+	}
+	if r.Matched[0].Rows != 2 {
+		t.Errorf("%d Garcia rows, want 2", r.Matched[0].Rows)
+	}
+	var teachers []string
+	for _, s := range s415s {
+		teachers = append(teachers, s.Teacher+" "+s.TeacherName)
+	}
+	want := []string{
+		"a.garcia@aps.edu Garcia, Ana",
+		"a.garcia@aps.edu Garcia, Ana",
+		"m.oconnor@aps.edu OConnor, Mary",
+		"Smith, John Smith, John",
+		"Nobody, Here Nobody, Here",
+	}
+	if !reflect.DeepEqual(teachers, want) {
+		t.Errorf("teachers %q, want %q", teachers, want)
+	}
+}